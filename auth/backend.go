@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Credential backends selectable through Options.Backend
+const (
+	BackendGcloud         = "gcloud"
+	BackendServiceAccount = "service-account"
+	BackendMetadata       = "metadata"
+	BackendStatic         = "static"
+)
+
+// Backends - every supported credential backend
+var Backends = []string{BackendGcloud, BackendServiceAccount, BackendMetadata, BackendStatic}
+
+// DefaultAccessScopes - scopes requested for access tokens
+var DefaultAccessScopes = []string{"https://www.googleapis.com/auth/cloud-platform"}
+
+// Options - selects and configures the credential backend
+type Options struct {
+	// Backend - one of Backends, BackendGcloud when empty
+	Backend string
+	// CredentialsFile - service account JSON key file, BackendServiceAccount only
+	CredentialsFile string
+	// TokenURL - overrides the token endpoint of the key file, BackendServiceAccount only
+	TokenURL string
+	// MetadataURL - metadata server, DefaultMetadataURL when empty, BackendMetadata only
+	MetadataURL string
+	// Audience - audience of identity tokens, ignored by BackendGcloud and BackendStatic
+	Audience string
+	// IdentityTokenEnv, IdentityTokenFile - where identity tokens are read from, BackendStatic only
+	IdentityTokenEnv  string
+	IdentityTokenFile string
+	// AccessTokenEnv, AccessTokenFile - where access tokens are read from, BackendStatic only
+	AccessTokenEnv  string
+	AccessTokenFile string
+	// NeedsAccessToken - Validate also checks the access token is set, BackendStatic only
+	NeedsAccessToken bool
+	// Log - token fetches are reported to it, os.Stdout when nil, BackendGcloud only
+	Log io.Writer
+}
+
+// Validate - checks the options of the selected backend are set, static tokens have to be readable
+func (o Options) Validate() error {
+	switch o.Backend {
+	case "", BackendGcloud:
+	case BackendStatic:
+		identity := &staticFetcher{env: o.IdentityTokenEnv, file: o.IdentityTokenFile}
+		if _, err := identity.Fetch(); err != nil {
+			return fmt.Errorf("auth backend %s needs an identity token: %v", o.Backend, err)
+		}
+		if o.NeedsAccessToken {
+			access := &staticFetcher{env: o.AccessTokenEnv, file: o.AccessTokenFile}
+			if _, err := access.Fetch(); err != nil {
+				return fmt.Errorf("auth backend %s needs an access token: %v", o.Backend, err)
+			}
+		}
+	case BackendServiceAccount, BackendMetadata:
+		if o.Backend == BackendServiceAccount && o.CredentialsFile == "" {
+			return fmt.Errorf("auth backend %s needs a credentials file", o.Backend)
+		}
+		if o.Audience == "" {
+			return fmt.Errorf("auth backend %s needs an audience", o.Backend)
+		}
+	default:
+		return fmt.Errorf("unknown auth backend %q, expected one of %s", o.Backend, strings.Join(Backends, ", "))
+	}
+	return nil
+}
+
+// NewIdentityTokenSource - returns a cached identity token source of the selected backend
+func NewIdentityTokenSource(o Options) (*CachingTokenSource, error) {
+	return newTokenSource(o, o.Audience, o.IdentityTokenEnv, o.IdentityTokenFile, GcloudIdentityToken)
+}
+
+// NewAccessTokenSource - returns a cached access token source of the selected backend
+func NewAccessTokenSource(o Options) (*CachingTokenSource, error) {
+	return newTokenSource(o, "", o.AccessTokenEnv, o.AccessTokenFile, GcloudAccessToken)
+}
+
+// newTokenSource - builds the backend fetcher, identity tokens are requested when audience is set
+func newTokenSource(o Options, audience, env, file string, gcloudFetch func(log io.Writer) (Token, error)) (*CachingTokenSource, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch o.Backend {
+	case BackendServiceAccount:
+		key, err := ReadServiceAccountKey(o.CredentialsFile)
+		if err != nil {
+			return nil, err
+		}
+		fetcher, err := newServiceAccountFetcher(key, o.TokenURL, audience, DefaultAccessScopes)
+		if err != nil {
+			return nil, err
+		}
+		return NewCachingTokenSource(fetcher.Fetch, fallbackTokenTTL), nil
+
+	case BackendMetadata:
+		return NewCachingTokenSource(newMetadataFetcher(o.MetadataURL, audience).Fetch, fallbackTokenTTL), nil
+
+	case BackendStatic:
+		fetcher := &staticFetcher{env: env, file: file}
+		return NewCachingTokenSource(fetcher.Fetch, fallbackTokenTTL), nil
+
+	default:
+		fetch := func() (Token, error) {
+			return gcloudFetch(o.Log)
+		}
+		return NewCachingTokenSource(fetch, fallbackTokenTTL), nil
+	}
+}
@@ -0,0 +1,378 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testJWT - returns an unsigned JWT with the exp claim, the signature is not checked by JWTExpiry
+func testJWT(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return header + "." + base64.RawURLEncoding.EncodeToString(payload) + ".c2ln"
+}
+
+func TestJWTExpiry(t *testing.T) {
+	exp := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	got, err := JWTExpiry(testJWT(t, map[string]any{"exp": exp.Unix(), "aud": "x"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(exp) {
+		t.Errorf("JWTExpiry = %s, want %s", got, exp)
+	}
+
+	for name, jwt := range map[string]string{
+		"opaque token":     "ya29.a0AfH6SM",
+		"two parts":        "a.b",
+		"payload not b64":  "a.!!!.c",
+		"payload not json": "a." + base64.RawURLEncoding.EncodeToString([]byte("exp")) + ".c",
+		"no exp":           testJWT(t, map[string]any{"aud": "x"}),
+	} {
+		if _, err := JWTExpiry(jwt); err == nil {
+			t.Errorf("%s: JWTExpiry succeeded, want an error", name)
+		}
+	}
+}
+
+// writeServiceAccountKey - writes a key file with a fresh RSA key, returns its path and public key
+func writeServiceAccountKey(t *testing.T) (string, *rsa.PublicKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(ServiceAccountKey{
+		Type:         "service_account",
+		ClientEmail:  "bot@qa-1234.iam.gserviceaccount.com",
+		PrivateKeyID: "kid-1",
+		PrivateKey:   string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		TokenURI:     "https://oauth2.invalid/token",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "key.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path, &key.PublicKey
+}
+
+// verifyAssertion - checks the RS256 signature of the assertion and returns its header and claims
+func verifyAssertion(pub *rsa.PublicKey, assertion string) (map[string]any, map[string]any, error) {
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 3 {
+		return nil, nil, fmt.Errorf("assertion has %d parts", len(parts))
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, err
+	}
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, hash[:], signature); err != nil {
+		return nil, nil, fmt.Errorf("signature: %v", err)
+	}
+
+	decoded := [2]map[string]any{}
+	for i := range decoded {
+		data, err := base64.RawURLEncoding.DecodeString(parts[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := json.Unmarshal(data, &decoded[i]); err != nil {
+			return nil, nil, err
+		}
+	}
+	return decoded[0], decoded[1], nil
+}
+
+func TestServiceAccountBackend(t *testing.T) {
+	keyFile, pub := writeServiceAccountKey(t)
+	idToken := testJWT(t, map[string]any{"exp": time.Now().Add(time.Hour).Unix()})
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.PostFormValue("grant_type"); got != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			http.Error(w, "grant_type "+got, http.StatusBadRequest)
+			return
+		}
+		header, claims, err := verifyAssertion(pub, r.PostFormValue("assertion"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if header["kid"] != "kid-1" || claims["iss"] != "bot@qa-1234.iam.gserviceaccount.com" || claims["aud"] != server.URL {
+			http.Error(w, fmt.Sprintf("unexpected assertion %v %v", header, claims), http.StatusBadRequest)
+			return
+		}
+
+		switch {
+		case claims["target_audience"] == "https://meta.example":
+			fmt.Fprintf(w, `{"id_token":%q}`, idToken)
+		case claims["scope"] == strings.Join(DefaultAccessScopes, " "):
+			w.Write([]byte(`{"access_token":"ya29.sa","expires_in":3600,"token_type":"Bearer"}`))
+		default:
+			http.Error(w, fmt.Sprintf("unexpected claims %v", claims), http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	o := Options{
+		Backend:         BackendServiceAccount,
+		CredentialsFile: keyFile,
+		TokenURL:        server.URL,
+		Audience:        "https://meta.example",
+	}
+
+	identity, err := NewIdentityTokenSource(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := identity.Token(); err != nil || got != idToken {
+		t.Errorf("identity Token() = %q, %v, want the id_token", got, err)
+	}
+
+	access, err := NewAccessTokenSource(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := access.Token(); err != nil || got != "ya29.sa" {
+		t.Errorf("access Token() = %q, %v, want ya29.sa", got, err)
+	}
+	if access.token.Expiry.IsZero() {
+		t.Error("access token expiry not taken from expires_in")
+	}
+}
+
+func TestServiceAccountBackendErrors(t *testing.T) {
+	keyFile, _ := writeServiceAccountKey(t)
+
+	for name, handler := range map[string]http.HandlerFunc{
+		"rejected": func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		},
+		"no id_token": func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"access_token":"ya29.sa"}`))
+		},
+		"id_token without exp": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"id_token":%q}`, testJWT(t, map[string]any{"aud": "x"}))
+		},
+	} {
+		server := httptest.NewServer(handler)
+		source, err := NewIdentityTokenSource(Options{
+			Backend:         BackendServiceAccount,
+			CredentialsFile: keyFile,
+			TokenURL:        server.URL,
+			Audience:        "https://meta.example",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := source.Token(); err == nil {
+			t.Errorf("%s: Token() succeeded, want an error", name)
+		}
+		server.Close()
+	}
+}
+
+func TestReadServiceAccountKeyInvalid(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"not json":       `{`,
+		"user creds":     `{"type":"authorized_user","client_email":"a","private_key":"b"}`,
+		"no private key": `{"type":"service_account","client_email":"a"}`,
+	} {
+		path := filepath.Join(dir, strings.ReplaceAll(name, " ", "_"))
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadServiceAccountKey(path); err == nil {
+			t.Errorf("%s: ReadServiceAccountKey succeeded, want an error", name)
+		}
+	}
+	if _, err := ReadServiceAccountKey(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("ReadServiceAccountKey of a missing file succeeded")
+	}
+}
+
+func TestMetadataBackend(t *testing.T) {
+	idToken := testJWT(t, map[string]any{"exp": time.Now().Add(time.Hour).Unix()})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			http.Error(w, "missing Metadata-Flavor", http.StatusForbidden)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/computeMetadata/v1/instance/service-accounts/default/identity":
+			if r.URL.Query().Get("audience") != "https://meta.example" || r.URL.Query().Get("format") != "full" {
+				http.Error(w, "bad query "+r.URL.RawQuery, http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(idToken + "\n"))
+		case "/computeMetadata/v1/instance/service-accounts/default/token":
+			w.Write([]byte(`{"access_token":"ya29.md","expires_in":1800,"token_type":"Bearer"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	// the metadata url is accepted without a scheme, like GCE_METADATA_HOST
+	o := Options{
+		Backend:     BackendMetadata,
+		MetadataURL: strings.TrimPrefix(server.URL, "http://") + "/",
+		Audience:    "https://meta.example",
+	}
+
+	identity, err := NewIdentityTokenSource(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := identity.Token(); err != nil || got != idToken {
+		t.Errorf("identity Token() = %q, %v, want the identity token", got, err)
+	}
+
+	access, err := NewAccessTokenSource(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := access.Token(); err != nil || got != "ya29.md" {
+		t.Errorf("access Token() = %q, %v, want ya29.md", got, err)
+	}
+}
+
+func TestMetadataBackendErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no service account", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	source, err := NewIdentityTokenSource(Options{Backend: BackendMetadata, MetadataURL: server.URL, Audience: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.Token(); err == nil || !strings.Contains(err.Error(), "status 404") {
+		t.Errorf("Token() error = %v, want the 404 of the metadata server", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("Bearer tok\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_IDENTITY_TOKEN", "")
+	t.Setenv("TEST_ACCESS_TOKEN", "")
+
+	static := Options{Backend: BackendStatic, IdentityTokenEnv: "TEST_IDENTITY_TOKEN", AccessTokenEnv: "TEST_ACCESS_TOKEN"}
+	withEnv := func(env string, o Options) func() Options {
+		return func() Options {
+			t.Setenv(env, "tok")
+			return o
+		}
+	}
+	reset := func() {
+		t.Setenv("TEST_IDENTITY_TOKEN", "")
+		t.Setenv("TEST_ACCESS_TOKEN", "")
+	}
+
+	tests := []struct {
+		name    string
+		options func() Options
+		valid   bool
+	}{
+		{"gcloud", func() Options { return Options{} }, true},
+		{"unknown backend", func() Options { return Options{Backend: "kerberos"} }, false},
+		{"service account without key", func() Options { return Options{Backend: BackendServiceAccount, Audience: "x"} }, false},
+		{"service account without audience", func() Options { return Options{Backend: BackendServiceAccount, CredentialsFile: "k.json"} }, false},
+		{"metadata without audience", func() Options { return Options{Backend: BackendMetadata} }, false},
+		{"static without identity token", func() Options { return static }, false},
+		{"static identity token from env", withEnv("TEST_IDENTITY_TOKEN", static), true},
+		{"static identity token from file", func() Options {
+			o := static
+			o.IdentityTokenFile = tokenFile
+			return o
+		}, true},
+		{"static identity token file missing", func() Options {
+			o := static
+			o.IdentityTokenFile = tokenFile + ".missing"
+			return o
+		}, false},
+		{"static without the needed access token", withEnv("TEST_IDENTITY_TOKEN", Options{
+			Backend: BackendStatic, IdentityTokenEnv: "TEST_IDENTITY_TOKEN", AccessTokenEnv: "TEST_ACCESS_TOKEN", NeedsAccessToken: true,
+		}), false},
+		{"static with the needed access token from file", withEnv("TEST_IDENTITY_TOKEN", Options{
+			Backend: BackendStatic, IdentityTokenEnv: "TEST_IDENTITY_TOKEN", AccessTokenFile: tokenFile, NeedsAccessToken: true,
+		}), true},
+	}
+
+	for _, tt := range tests {
+		reset()
+		err := tt.options().Validate()
+		if tt.valid && err != nil {
+			t.Errorf("%s: Validate: %v", tt.name, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("%s: Validate succeeded, want an error", tt.name)
+		}
+	}
+}
+
+func TestStaticBackend(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("first\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_IDENTITY_TOKEN", "Bearer from-env")
+
+	source, err := NewIdentityTokenSource(Options{Backend: BackendStatic, IdentityTokenEnv: "TEST_IDENTITY_TOKEN"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := source.Token(); err != nil || got != "from-env" {
+		t.Errorf("Token() = %q, %v, want from-env", got, err)
+	}
+
+	// the file wins over the env variable and is read again on refresh
+	source, err = NewIdentityTokenSource(Options{Backend: BackendStatic, IdentityTokenEnv: "TEST_IDENTITY_TOKEN", IdentityTokenFile: tokenFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := source.Token(); got != "first" {
+		t.Errorf("Token() = %q, want first", got)
+	}
+	if err := os.WriteFile(tokenFile, []byte("second"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := source.Refresh(); got != "second" {
+		t.Errorf("Refresh() = %q, want second", got)
+	}
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// fallbackTokenTTL - lifetime assumed for tokens whose expiry could not be found
+const fallbackTokenTTL = 15 * time.Minute
+
+// tokenInfoURL - Googles tokeninfo api, looks up the expiry of access tokens
+const tokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+
+// GcloudIdentityToken - fetches Googles Identity token through the gcloud CLI, reporting to log or os.Stdout when nil
+func GcloudIdentityToken(log io.Writer) (Token, error) {
+	if log == nil {
+		log = os.Stdout
+	}
+	fmt.Fprintln(log, "Fetching Identity Token...")
+
+	value, err := gcloud("auth", "print-identity-token")
+	if err != nil {
+		return Token{}, err
+	}
+
+	expiry, err := JWTExpiry(value)
+	if err != nil {
+		fmt.Fprintf(log, "Identity token expiry unknown: %v\n", err)
+	}
+
+	return Token{Value: value, Expiry: expiry}, nil
+}
+
+// GcloudAccessToken - fetches Googles Access token through the gcloud CLI, reporting to log or os.Stdout when nil
+func GcloudAccessToken(log io.Writer) (Token, error) {
+	if log == nil {
+		log = os.Stdout
+	}
+	fmt.Fprintln(log, "Fetching Access Token...")
+
+	value, err := gcloud("auth", "print-access-token")
+	if err != nil {
+		return Token{}, err
+	}
+
+	expiry, err := accessTokenExpiry(tokenInfoURL, value)
+	if err != nil {
+		fmt.Fprintf(log, "Access token expiry unknown: %v\n", err)
+	}
+
+	return Token{Value: value, Expiry: expiry}, nil
+}
+
+// gcloud - runs the gcloud CLI and returns its trimmed output
+func gcloud(args ...string) (string, error) {
+	out, err := exec.Command("gcloud", args...).Output()
+	if err != nil {
+		exitErr := &exec.ExitError{}
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("gcloud %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("gcloud %s: %v", strings.Join(args, " "), err)
+	}
+
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return "", fmt.Errorf("gcloud %s: empty token", strings.Join(args, " "))
+	}
+
+	return token, nil
+}
+
+// JWTExpiry - returns the exp claim of a JWT, without verifying its signature
+func JWTExpiry(jwt string) (time.Time, error) {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return time.Time{}, errors.New("token is not a JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("decode JWT payload: %v", err)
+	}
+
+	claims := struct {
+		Exp int64 `json:"exp"`
+	}{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("decode JWT claims: %v", err)
+	}
+	if claims.Exp == 0 {
+		return time.Time{}, errors.New("JWT has no exp claim")
+	}
+
+	return time.Unix(claims.Exp, 0), nil
+}
+
+// accessTokenExpiry - looks up the expiry of an access token with the tokeninfo api at infoURL
+//
+// The token is posted in the form body, so it does not end up in the logs of proxies on the way.
+func accessTokenExpiry(infoURL, token string) (time.Time, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	response, err := client.PostForm(infoURL, url.Values{"access_token": {token}})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("tokeninfo: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return time.Time{}, fmt.Errorf("tokeninfo: status %d", response.StatusCode)
+	}
+
+	info := struct {
+		ExpiresIn json.Number `json:"expires_in"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&info); err != nil {
+		return time.Time{}, fmt.Errorf("tokeninfo: %v", err)
+	}
+
+	seconds, err := info.ExpiresIn.Int64()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("tokeninfo expires_in: %v", err)
+	}
+
+	return time.Now().Add(time.Duration(seconds) * time.Second), nil
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAccessTokenExpiry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method %s, want POST", r.Method)
+		}
+		if r.URL.RawQuery != "" {
+			t.Errorf("query %q, the token must not be in the url", r.URL.RawQuery)
+		}
+		if got := r.PostFormValue("access_token"); got != "ya29.secret" {
+			t.Errorf("access_token %q, want ya29.secret", got)
+		}
+		w.Write([]byte(`{"azp":"x","expires_in":"3599"}`))
+	}))
+	defer server.Close()
+
+	before := time.Now()
+	expiry, err := accessTokenExpiry(server.URL, "ya29.secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expiry.Before(before.Add(3599*time.Second)) || expiry.After(time.Now().Add(3599*time.Second)) {
+		t.Errorf("expiry %s, want about an hour from now", expiry)
+	}
+}
+
+func TestAccessTokenExpiryErrors(t *testing.T) {
+	for name, handler := range map[string]http.HandlerFunc{
+		"rejected": func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"error":"invalid_token"}`, http.StatusBadRequest)
+		},
+		"no expires_in": func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"azp":"x"}`))
+		},
+		"not json": func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`<html>`))
+		},
+	} {
+		server := httptest.NewServer(handler)
+		if _, err := accessTokenExpiry(server.URL, "ya29.secret"); err == nil {
+			t.Errorf("%s: accessTokenExpiry succeeded, want an error", name)
+		}
+		server.Close()
+	}
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultMetadataURL - GCE metadata server
+const DefaultMetadataURL = "http://metadata.google.internal"
+
+// metadataFetcher - fetches tokens of the default service account from a metadata server
+type metadataFetcher struct {
+	baseURL    string
+	audience   string
+	httpClient *http.Client
+}
+
+// newMetadataFetcher - returns a fetcher for identity tokens when audience is set, access tokens otherwise
+func newMetadataFetcher(baseURL, audience string) *metadataFetcher {
+	if baseURL == "" {
+		baseURL = DefaultMetadataURL
+	}
+	if !strings.Contains(baseURL, "://") {
+		baseURL = "http://" + baseURL
+	}
+
+	return &metadataFetcher{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		audience:   audience,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Fetch - requests a token from the metadata server
+func (f *metadataFetcher) Fetch() (Token, error) {
+	path := f.baseURL + "/computeMetadata/v1/instance/service-accounts/default/"
+	if f.audience != "" {
+		path += "identity?" + url.Values{"audience": {f.audience}, "format": {"full"}}.Encode()
+	} else {
+		path += "token"
+	}
+
+	request, err := http.NewRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return Token{}, fmt.Errorf("http.NewRequest: %v", err)
+	}
+	request.Header.Set("Metadata-Flavor", "Google")
+
+	now := time.Now()
+	response, err := f.httpClient.Do(request)
+	if err != nil {
+		return Token{}, fmt.Errorf("metadata server: %v", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return Token{}, fmt.Errorf("metadata server: %v", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return Token{}, fmt.Errorf("metadata server: status %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	if f.audience != "" {
+		value := strings.TrimSpace(string(body))
+		expiry, err := JWTExpiry(value)
+		if err != nil {
+			return Token{}, fmt.Errorf("metadata server: %v", err)
+		}
+		return Token{Value: value, Expiry: expiry}, nil
+	}
+
+	res := struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}{}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return Token{}, fmt.Errorf("metadata server: %v", err)
+	}
+
+	token := Token{Value: res.AccessToken}
+	if res.ExpiresIn > 0 {
+		token.Expiry = now.Add(time.Duration(res.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultTokenURL - Googles OAuth2 token endpoint
+const DefaultTokenURL = "https://oauth2.googleapis.com/token"
+
+// ServiceAccountKey - the fields of a service account JSON key file used to sign assertions
+type ServiceAccountKey struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// ReadServiceAccountKey - reads and validates a service account JSON key file
+func ReadServiceAccountKey(name string) (*ServiceAccountKey, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("read service account key: %v", err)
+	}
+
+	key := &ServiceAccountKey{}
+	if err := json.Unmarshal(data, key); err != nil {
+		return nil, fmt.Errorf("decode service account key %s: %v", name, err)
+	}
+	if key.Type != "service_account" {
+		return nil, fmt.Errorf("%s is of type %q, expected service_account", name, key.Type)
+	}
+	if key.ClientEmail == "" || key.PrivateKey == "" {
+		return nil, fmt.Errorf("%s has no client_email or private_key", name)
+	}
+
+	return key, nil
+}
+
+// serviceAccountFetcher - exchanges self signed JWT assertions for tokens
+type serviceAccountFetcher struct {
+	key        *ServiceAccountKey
+	signer     *rsa.PrivateKey
+	tokenURL   string
+	audience   string
+	scopes     []string
+	httpClient *http.Client
+}
+
+// newServiceAccountFetcher - returns a fetcher for identity tokens when audience is set, access tokens otherwise
+func newServiceAccountFetcher(key *ServiceAccountKey, tokenURL, audience string, scopes []string) (*serviceAccountFetcher, error) {
+	signer, err := parsePrivateKey(key.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if tokenURL == "" {
+		tokenURL = key.TokenURI
+	}
+	if tokenURL == "" {
+		tokenURL = DefaultTokenURL
+	}
+
+	return &serviceAccountFetcher{
+		key:        key,
+		signer:     signer,
+		tokenURL:   tokenURL,
+		audience:   audience,
+		scopes:     scopes,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Fetch - signs an assertion and exchanges it at the token endpoint
+func (f *serviceAccountFetcher) Fetch() (Token, error) {
+	now := time.Now()
+	claims := map[string]any{
+		"iss": f.key.ClientEmail,
+		"aud": f.tokenURL,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	if f.audience != "" {
+		claims["target_audience"] = f.audience
+	} else {
+		claims["scope"] = strings.Join(f.scopes, " ")
+	}
+
+	assertion, err := f.sign(claims)
+	if err != nil {
+		return Token{}, err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+
+	response, err := f.httpClient.PostForm(f.tokenURL, form)
+	if err != nil {
+		return Token{}, fmt.Errorf("token exchange: %v", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return Token{}, fmt.Errorf("token exchange: %v", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return Token{}, fmt.Errorf("token exchange: status %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	res := struct {
+		AccessToken string `json:"access_token"`
+		IDToken     string `json:"id_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}{}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return Token{}, fmt.Errorf("token exchange: %v", err)
+	}
+
+	if f.audience != "" {
+		if res.IDToken == "" {
+			return Token{}, errors.New("token exchange: no id_token in response")
+		}
+		expiry, err := JWTExpiry(res.IDToken)
+		if err != nil {
+			return Token{}, fmt.Errorf("token exchange: %v", err)
+		}
+		return Token{Value: res.IDToken, Expiry: expiry}, nil
+	}
+
+	if res.AccessToken == "" {
+		return Token{}, errors.New("token exchange: no access_token in response")
+	}
+
+	token := Token{Value: res.AccessToken}
+	if res.ExpiresIn > 0 {
+		token.Expiry = now.Add(time.Duration(res.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// sign - returns the RS256 signed JWT of the claims
+func (f *serviceAccountFetcher) sign(claims map[string]any) (string, error) {
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
+	if f.key.PrivateKeyID != "" {
+		header["kid"] = f.key.PrivateKeyID
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", fmt.Errorf("json.Marshal: %v", err)
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("json.Marshal: %v", err)
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, f.signer, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", fmt.Errorf("sign assertion: %v", err)
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parsePrivateKey - parses a PEM encoded PKCS8 or PKCS1 RSA private key
+func parsePrivateKey(privateKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(privateKey))
+	if block == nil {
+		return nil, errors.New("private_key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse private_key: %v", err)
+	}
+
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private_key is not an RSA key")
+	}
+
+	return key, nil
+}
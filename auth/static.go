@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// staticFetcher - reads a token from an env variable or a file, the file is re-read on every fetch
+type staticFetcher struct {
+	env  string
+	file string
+}
+
+// Fetch - returns the token from the file when set, the env variable otherwise
+func (f *staticFetcher) Fetch() (Token, error) {
+	value := ""
+
+	switch {
+	case f.file != "":
+		data, err := os.ReadFile(f.file)
+		if err != nil {
+			return Token{}, fmt.Errorf("read token file: %v", err)
+		}
+		value = string(data)
+	case f.env != "":
+		value = os.Getenv(f.env)
+	}
+
+	value = strings.TrimPrefix(strings.TrimSpace(value), "Bearer ")
+	if value == "" {
+		return Token{}, fmt.Errorf("no static token in %s", f.location())
+	}
+
+	// Expiry is only known for JWTs, other tokens are kept for the fallback ttl of the cache and read again after it
+	expiry, _ := JWTExpiry(value)
+	return Token{Value: value, Expiry: expiry}, nil
+}
+
+// location - describes where the token is read from
+func (f *staticFetcher) location() string {
+	if f.file != "" {
+		return "file " + f.file
+	}
+	return "env variable " + f.env
+}
@@ -1,25 +1,94 @@
 package auth
 
 import (
-	"fmt"
-	"os/exec"
-	"strings"
+	"errors"
+	"sync"
+	"time"
 )
 
-// GetIdentityToken - returns Googles Identity token
-func GetIdentityToken() string {
-	out, err := exec.Command("gcloud", "auth", "print-identity-token").Output()
-	if err != nil {
-		fmt.Println(err)
+// DefaultExpiryMargin - cached tokens are re-fetched this long before they expire
+const DefaultExpiryMargin = 5 * time.Minute
+
+// TokenSource - supplies the token sent as bearer, without the "Bearer " prefix
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// Token - a token along with the time it expires at
+type Token struct {
+	Value string
+	// Expiry - zero when the expiry is unknown
+	Expiry time.Time
+}
+
+// FetchFunc - fetches a new token
+type FetchFunc func() (Token, error)
+
+// CachingTokenSource - caches the fetched token until shortly before it expires, safe for concurrent use
+type CachingTokenSource struct {
+	fetch  FetchFunc
+	margin time.Duration
+	// fallbackTTL - lifetime assumed for tokens without an expiry
+	fallbackTTL time.Duration
+	now         func() time.Time
+
+	mu      sync.Mutex
+	token   Token
+	fetched time.Time
+}
+
+// NewCachingTokenSource - returns a TokenSource caching the tokens of fetch
+//
+// Tokens without an expiry are kept for fallbackTTL.
+func NewCachingTokenSource(fetch FetchFunc, fallbackTTL time.Duration) *CachingTokenSource {
+	return &CachingTokenSource{
+		fetch:       fetch,
+		margin:      DefaultExpiryMargin,
+		fallbackTTL: fallbackTTL,
+		now:         time.Now,
+	}
+}
+
+// Token - returns the cached token, fetching a new one when it is about to expire
+func (s *CachingTokenSource) Token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token.Value != "" && s.now().Before(s.expiry()) {
+		return s.token.Value, nil
 	}
-	return fmt.Sprintf("Bearer %s", strings.ReplaceAll(strings.ReplaceAll(string(out), "\r", ""), "\n", ""))
+
+	return s.refresh()
+}
+
+// Refresh - fetches a new token regardless of the cached one
+func (s *CachingTokenSource) Refresh() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.refresh()
 }
 
-// GetAccessToken - returns Googles Access token
-func GetAccessToken() string {
-	out, err := exec.Command("gcloud", "auth", "print-access-token").Output()
+// refresh - fetches and caches a token, s.mu must be held
+func (s *CachingTokenSource) refresh() (string, error) {
+	token, err := s.fetch()
 	if err != nil {
-		fmt.Println(err)
+		return "", err
+	}
+	if token.Value == "" {
+		return "", errors.New("empty token fetched")
+	}
+
+	s.token = token
+	s.fetched = s.now()
+
+	return token.Value, nil
+}
+
+// expiry - returns the time the cached token has to be re-fetched at, s.mu must be held
+func (s *CachingTokenSource) expiry() time.Time {
+	if s.token.Expiry.IsZero() {
+		return s.fetched.Add(s.fallbackTTL)
 	}
-	return fmt.Sprintf("Bearer %s", strings.ReplaceAll(strings.ReplaceAll(string(out), "\r", ""), "\n", ""))
+	return s.token.Expiry.Add(-s.margin)
 }
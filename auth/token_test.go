@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeClock - a settable clock for CachingTokenSource.now
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+// countingFetch - returns tokens tok-1, tok-2... expiring ttl after the clock, no expiry when ttl is 0
+func countingFetch(clock *fakeClock, ttl time.Duration, calls *int) FetchFunc {
+	return func() (Token, error) {
+		*calls++
+		token := Token{Value: fmt.Sprintf("tok-%d", *calls)}
+		if ttl > 0 {
+			token.Expiry = clock.t.Add(ttl)
+		}
+		return token, nil
+	}
+}
+
+func newTestSource(fetch FetchFunc, clock *fakeClock, fallbackTTL time.Duration) *CachingTokenSource {
+	s := NewCachingTokenSource(fetch, fallbackTTL)
+	s.now = clock.now
+	return s
+}
+
+func TestCachingTokenSourceRefetchesBeforeExpiry(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	calls := 0
+	s := newTestSource(countingFetch(clock, time.Hour, &calls), clock, 15*time.Minute)
+
+	steps := []struct {
+		after time.Duration
+		want  string
+	}{
+		{0, "tok-1"},
+		{30 * time.Minute, "tok-1"},
+		// the token expires at 01:00, so it is kept until 00:55
+		{54*time.Minute + 59*time.Second, "tok-1"},
+		{55 * time.Minute, "tok-2"},
+		{time.Hour, "tok-2"},
+	}
+	for _, step := range steps {
+		clock.t = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).Add(step.after)
+		got, err := s.Token()
+		if err != nil {
+			t.Fatalf("Token() after %s: %v", step.after, err)
+		}
+		if got != step.want {
+			t.Errorf("Token() after %s = %q, want %q", step.after, got, step.want)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("%d fetches, want 2", calls)
+	}
+}
+
+func TestCachingTokenSourceFallbackTTL(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	calls := 0
+	s := newTestSource(countingFetch(clock, 0, &calls), clock, 15*time.Minute)
+
+	for _, step := range []struct {
+		after time.Duration
+		want  string
+	}{
+		{0, "tok-1"},
+		{14 * time.Minute, "tok-1"},
+		{15 * time.Minute, "tok-2"},
+		{29 * time.Minute, "tok-2"},
+		{30 * time.Minute, "tok-3"},
+	} {
+		clock.t = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).Add(step.after)
+		if got, err := s.Token(); err != nil || got != step.want {
+			t.Errorf("Token() after %s = %q, %v, want %q", step.after, got, err, step.want)
+		}
+	}
+}
+
+func TestCachingTokenSourceExpiredToken(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	calls := 0
+	// tokens expiring within the margin are never served from the cache
+	s := newTestSource(countingFetch(clock, time.Minute, &calls), clock, 15*time.Minute)
+
+	for range 3 {
+		if _, err := s.Token(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("%d fetches, want 3", calls)
+	}
+}
+
+func TestCachingTokenSourceRefresh(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	calls := 0
+	s := newTestSource(countingFetch(clock, time.Hour, &calls), clock, 15*time.Minute)
+
+	if got, _ := s.Token(); got != "tok-1" {
+		t.Fatalf("Token() = %q, want tok-1", got)
+	}
+	if got, err := s.Refresh(); err != nil || got != "tok-2" {
+		t.Fatalf("Refresh() = %q, %v, want tok-2", got, err)
+	}
+	if got, _ := s.Token(); got != "tok-2" {
+		t.Errorf("Token() after Refresh = %q, want tok-2", got)
+	}
+}
+
+func TestCachingTokenSourceErrors(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	fail := true
+	calls := 0
+	s := newTestSource(func() (Token, error) {
+		calls++
+		if fail {
+			return Token{}, errors.New("backend down")
+		}
+		return Token{Value: "tok", Expiry: clock.t.Add(time.Hour)}, nil
+	}, clock, 15*time.Minute)
+
+	if _, err := s.Token(); err == nil {
+		t.Fatal("Token() succeeded with a failing fetch")
+	}
+	// failures are not cached
+	fail = false
+	if got, err := s.Token(); err != nil || got != "tok" {
+		t.Errorf("Token() = %q, %v, want tok", got, err)
+	}
+	if calls != 2 {
+		t.Errorf("%d fetches, want 2", calls)
+	}
+
+	empty := newTestSource(func() (Token, error) { return Token{}, nil }, clock, time.Minute)
+	if _, err := empty.Token(); err == nil {
+		t.Error("Token() succeeded with an empty token")
+	}
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mah35h95/break-time/auth"
+	"github.com/mah35h95/break-time/dice"
+	"github.com/mah35h95/break-time/journal"
+	"github.com/mah35h95/break-time/transport"
+	"github.com/mah35h95/break-time/utils"
+)
+
+// defaultChunkSize - number of concurrent workers when CHUNK_SIZE is not set
+const defaultChunkSize = 5
+
+// options - flags shared by every subcommand
+type options struct {
+	Project string
+	// MetaURL - overrides the meta service url derived from the project
+	MetaURL   string
+	Jobs      string
+	JobsFiles stringsFlag
+	ChunkSize int
+	Auth      auth.Options
+
+	MetaRPS      float64
+	MetaBurst    int
+	StorageRPS   float64
+	StorageBurst int
+
+	RequestTimeout  time.Duration
+	ShutdownTimeout time.Duration
+
+	Retry    transport.RetryPolicy
+	CmdRetry cmdRetryFlag
+	// RetrySafe - extra dice commands that may be retried
+	RetrySafe string
+
+	RunsDir string
+	// Resume - id of the run to resume
+	Resume string
+	// DryRun - print and record the mutating requests instead of sending them
+	DryRun bool
+	// YesIMeanProd - skips the confirmation of destructive commands against prod projects
+	YesIMeanProd bool
+
+	// Select - --select terms choosing jobs from the meta service
+	Select stringsFlag
+
+	// selector - parsed Select, nil when not given
+	selector *selector
+	// defaultJobs - jobs listed by the command or selected with --select when neither
+	// --jobs nor --jobs-file is given
+	defaultJobs []utils.JobEntry
+}
+
+// stringsFlag - repeatable string flag
+type stringsFlag []string
+
+// String - implements flag.Value
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+// Set - implements flag.Value
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// cmdRetryFlag - repeatable <dice cmd>=<max attempts> flag
+type cmdRetryFlag map[string]int
+
+// String - implements flag.Value
+func (f cmdRetryFlag) String() string {
+	pairs := []string{}
+	for cmd, attempts := range f {
+		pairs = append(pairs, fmt.Sprintf("%s=%d", cmd, attempts))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Set - implements flag.Value, takes one or comma separated pairs
+func (f cmdRetryFlag) Set(value string) error {
+	for _, pair := range strings.Split(value, ",") {
+		cmd, attempts, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("expected <cmd>=<max attempts>, got %q", pair)
+		}
+
+		n, err := strconv.Atoi(attempts)
+		if err != nil || n < 1 {
+			return fmt.Errorf("max attempts of %s must be a number >= 1, got %q", cmd, attempts)
+		}
+
+		f[strings.ReplaceAll(cmd, "-", "_")] = n
+	}
+	return nil
+}
+
+// SafeCmds - returns dice.RetrySafeCmds along with the commands of --retry-safe
+func (o options) SafeCmds() map[string]bool {
+	safeCmds := maps.Clone(dice.RetrySafeCmds)
+	for _, cmd := range strings.Split(o.RetrySafe, ",") {
+		if cmd = strings.TrimSpace(cmd); cmd != "" {
+			safeCmds[strings.ReplaceAll(cmd, "-", "_")] = true
+		}
+	}
+	return safeCmds
+}
+
+// CmdRetryPolicies - returns the --retry-cmd overrides of the retry policy
+func (o options) CmdRetryPolicies() map[string]transport.RetryPolicy {
+	policies := map[string]transport.RetryPolicy{}
+	for cmd, attempts := range o.CmdRetry {
+		policy := o.Retry
+		policy.MaxAttempts = attempts
+		policies[cmd] = policy
+	}
+	return policies
+}
+
+// MetaSvcUrl - returns the dice meta service url for the project
+func (o options) MetaSvcUrl() string {
+	if o.MetaURL != "" {
+		return o.MetaURL
+	}
+	return fmt.Sprintf("https://dice-meta-svc-dot-%s.appspot.com", o.Project)
+}
+
+// BucketName - returns the dice fs bucket for the project
+func (o options) BucketName() string {
+	return fmt.Sprintf("%s-dice-fs", o.Project)
+}
+
+// parseArgs - resolves the subcommand and its flags, falling back to the CMD env variable
+func parseArgs(args []string) (*command, options, jobFunc, error) {
+	opts := options{}
+
+	name := ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	} else if cmd, ok := os.LookupEnv("CMD"); ok && len(cmd) > 0 {
+		name = cmd
+	}
+
+	if name == "" || name == "help" {
+		if len(args) > 0 {
+			if cmd := findCommand(args[0]); cmd != nil {
+				fs := cmd.flagSet(&opts)
+				cmd.setup(fs)
+				fs.Usage()
+				return nil, opts, nil, flag.ErrHelp
+			}
+		}
+		printUsage(os.Stderr)
+		if name == "help" {
+			return nil, opts, nil, flag.ErrHelp
+		}
+		return nil, opts, nil, errors.New("no command provided")
+	}
+
+	cmd := findCommand(name)
+	if cmd == nil {
+		printUsage(os.Stderr)
+		return nil, opts, nil, fmt.Errorf("unknown command %q", name)
+	}
+
+	fs := cmd.flagSet(&opts)
+	run, check := cmd.setup(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, opts, nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, opts, nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	if (len(opts.Select) > 0 || len(opts.JobsFiles) > 0) && !flagGiven(fs, "jobs") {
+		// a JOBS env variable left over from the launch config is only used when no other job source is given
+		opts.Jobs = ""
+	}
+
+	if len(opts.Select) > 0 {
+		if opts.Resume != "" || opts.Jobs != "" || len(opts.JobsFiles) > 0 {
+			return nil, opts, nil, errors.New("--select can not be combined with --jobs, --jobs-file or --resume")
+		}
+		selector, err := parseSelector(opts.Select)
+		if err != nil {
+			return nil, opts, nil, err
+		}
+		opts.selector = selector
+	}
+
+	if opts.Resume != "" {
+		var err error
+		if fs, opts, run, check, err = resumeRun(cmd, fs, opts); err != nil {
+			return nil, opts, nil, err
+		}
+	} else if opts.selector == nil && cmd.defaultJobs != nil && opts.Jobs == "" && len(opts.JobsFiles) == 0 {
+		entries, err := cmd.defaultJobs(fs, opts)
+		if err != nil {
+			return nil, opts, nil, err
+		}
+		opts.defaultJobs = entries
+	}
+
+	if check != nil {
+		if err := check(); err != nil {
+			return nil, opts, nil, err
+		}
+	}
+
+	opts.Auth.NeedsAccessToken = cmd.storage
+	if err := opts.validate(); err != nil {
+		return nil, opts, nil, err
+	}
+
+	return cmd, opts, run, nil
+}
+
+// flagSet - builds the flag set holding the shared flags for a command
+func (c *command) flagSet(opts *options) *flag.FlagSet {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+
+	fs.StringVar(&opts.Project, "project", os.Getenv("PROJECT"), "GCP project hosting dice (env PROJECT)")
+	fs.StringVar(&opts.MetaURL, "meta-svc-url", os.Getenv("META_SVC_URL"), "meta service url, defaults to the one of the project (env META_SVC_URL)")
+	fs.StringVar(&opts.Jobs, "jobs", os.Getenv("JOBS"), "'/' separated list of dataSourceIds (env JOBS)")
+	fs.Var(&opts.Select, "select", "select jobs from the meta service instead of --jobs, repeatable; source=, technology= and database= are required, "+
+		"name=<glob> or name~<regexp> match <schema>.<table>, other keys match job fields, eg. newLakeJob=false or 'schedule~^0 0 '; != and !~ negate")
+	fs.Var(&opts.JobsFiles, "jobs-file", "job list file, directory or glob, '-' for stdin, repeatable; one dataSourceId per line, csv or json array; JOBS env is ignored when given")
+	fs.IntVar(&opts.ChunkSize, "chunk-size", envInt("CHUNK_SIZE", defaultChunkSize), "number of jobs run concurrently by the worker pool (env CHUNK_SIZE)")
+
+	fs.StringVar(&opts.Auth.Backend, "auth", envOr("AUTH_BACKEND", auth.BackendGcloud), "credential backend, one of "+strings.Join(auth.Backends, ", ")+" (env AUTH_BACKEND)")
+	fs.StringVar(&opts.Auth.CredentialsFile, "credentials-file", os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"), "service account JSON key file (env GOOGLE_APPLICATION_CREDENTIALS)")
+	fs.StringVar(&opts.Auth.TokenURL, "token-url", "", "token endpoint overriding the one of the service account key")
+	fs.StringVar(&opts.Auth.MetadataURL, "metadata-url", os.Getenv("GCE_METADATA_HOST"), "metadata server url (env GCE_METADATA_HOST)")
+	fs.StringVar(&opts.Auth.Audience, "audience", "", "audience of identity tokens, defaults to the meta service url")
+	fs.StringVar(&opts.Auth.IdentityTokenFile, "identity-token-file", "", "file holding the identity token, static auth only (default env IDENTITY_TOKEN)")
+	fs.StringVar(&opts.Auth.AccessTokenFile, "access-token-file", "", "file holding the access token, static auth only (default env ACCESS_TOKEN)")
+
+	fs.Float64Var(&opts.MetaRPS, "meta-rps", 10, "max meta service requests per second, 0 for no limit")
+	fs.IntVar(&opts.MetaBurst, "meta-burst", 10, "max burst of meta service requests")
+	fs.Float64Var(&opts.StorageRPS, "storage-rps", 20, "max GCS requests per second, 0 for no limit")
+	fs.IntVar(&opts.StorageBurst, "storage-burst", 20, "max burst of GCS requests")
+
+	fs.DurationVar(&opts.RequestTimeout, "request-timeout", 2*time.Minute, "timeout of a single http request including its retries")
+	fs.DurationVar(&opts.ShutdownTimeout, "shutdown-timeout", 30*time.Second, "time running jobs get to finish after Ctrl-C")
+
+	fs.StringVar(&opts.RunsDir, "runs-dir", envOr("RUNS_DIR", "runs"), "directory the run journals are kept in (env RUNS_DIR)")
+	fs.StringVar(&opts.Resume, "resume", "", "id of a run to resume with the flags it was started with, jobs that completed in it are skipped")
+	fs.BoolVar(&opts.YesIMeanProd, "yes-i-mean-prod", false, "run destructive commands against prod projects without typing the project name")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "print the requests that would change jobs instead of sending them, they are also written to <run dir>/dry-run.jsonl")
+
+	opts.CmdRetry = cmdRetryFlag{}
+	fs.IntVar(&opts.Retry.MaxAttempts, "max-attempts", transport.DefaultRetryPolicy.MaxAttempts, "attempts per request on connection errors, 429 and 5xx, 1 disables retries")
+	fs.DurationVar(&opts.Retry.BaseDelay, "retry-base-delay", transport.DefaultRetryPolicy.BaseDelay, "delay before the first retry, doubled for every following one")
+	fs.DurationVar(&opts.Retry.MaxDelay, "retry-max-delay", transport.DefaultRetryPolicy.MaxDelay, "max delay between retries, 0 for no cap")
+	fs.Var(opts.CmdRetry, "retry-cmd", "max attempts of a single dice command, eg. pause=6, repeatable")
+	fs.StringVar(&opts.RetrySafe, "retry-safe", "", "comma separated POST commands to retry on top of the safe ones, eg. load,reload")
+
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "Usage: break-time %s [flags]\n\n%s\n\nFlags:\n", c.name, c.help)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// resumableFlags - shared flags that may be changed when resuming a run, they tune how the
+// run is executed but not what it does to the jobs
+var resumableFlags = map[string]bool{
+	"resume": true, "runs-dir": true, "project": true, "meta-svc-url": true, "chunk-size": true, "yes-i-mean-prod": true,
+	"auth": true, "credentials-file": true, "token-url": true, "metadata-url": true, "audience": true,
+	"identity-token-file": true, "access-token-file": true,
+	"meta-rps": true, "meta-burst": true, "storage-rps": true, "storage-burst": true,
+	"request-timeout": true, "shutdown-timeout": true,
+	"max-attempts": true, "retry-base-delay": true, "retry-max-delay": true, "retry-cmd": true, "retry-safe": true,
+}
+
+// resumeRun - parses the flags recorded in the resumed run again, so it runs with the same command flags
+//
+// Flags of resumableFlags given along with --resume override the recorded ones, any other
+// flag given has to match the recorded value. The jobs are the ones of the run.
+func resumeRun(cmd *command, given *flag.FlagSet, opts options) (*flag.FlagSet, options, jobFunc, checkFunc, error) {
+	run, err := journal.ReadRun(opts.RunsDir, opts.Resume)
+	if err != nil {
+		return nil, opts, nil, nil, err
+	}
+	if run.Command != cmd.name {
+		return nil, opts, nil, nil, fmt.Errorf("run %s was a %s run, resume it with 'break-time %s --resume %s'", run.ID, run.Command, run.Command, run.ID)
+	}
+
+	recorded := options{}
+	fs := cmd.flagSet(&recorded)
+	jobRun, check := cmd.setup(fs)
+
+	args := run.Args
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		args = args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, opts, nil, nil, fmt.Errorf("flags of run %s: %v", run.ID, err)
+	}
+
+	errs := []error{}
+	given.Visit(func(f *flag.Flag) {
+		if resumableFlags[f.Name] {
+			if err := fs.Set(f.Name, f.Value.String()); err != nil {
+				errs = append(errs, fmt.Errorf("--%s: %v", f.Name, err))
+			}
+			return
+		}
+		if value := fs.Lookup(f.Name).Value.String(); f.Value.String() != value {
+			errs = append(errs, fmt.Errorf("--%s=%s differs from --%s=%s of run %s, start a new run to change it", f.Name, f.Value, f.Name, value, run.ID))
+		}
+	})
+	if len(errs) > 0 {
+		return nil, opts, nil, nil, errors.Join(errs...)
+	}
+
+	// env fallbacks of today do not override the project and meta service of the run
+	if !flagGiven(given, "project") {
+		recorded.Project = run.Project
+	}
+	if !flagGiven(given, "meta-svc-url") {
+		recorded.MetaURL = run.MetaURL
+	}
+	recorded.Jobs, recorded.JobsFiles, recorded.Select = "", nil, nil
+
+	if err := recorded.applyRun(run); err != nil {
+		return nil, opts, nil, nil, err
+	}
+	return fs, recorded, jobRun, check, nil
+}
+
+// applyRun - checks the resumed run is run against the same project and meta service the same way
+func (o *options) applyRun(run journal.Run) error {
+	if run.DryRun != o.DryRun {
+		return fmt.Errorf("run %s was run with --dry-run=%t, resume it the same way", run.ID, run.DryRun)
+	}
+	if o.Project != run.Project {
+		return fmt.Errorf("run %s was against project %s, not %s", run.ID, run.Project, o.Project)
+	}
+	if o.MetaURL != run.MetaURL {
+		return fmt.Errorf("run %s was sent to meta service %q, not %q", run.ID, run.MetaURL, o.MetaURL)
+	}
+
+	return nil
+}
+
+// validate - checks the shared flags are usable
+func (o *options) validate() error {
+	if o.Project == "" {
+		return errors.New("--project (or PROJECT env variable) is required")
+	}
+	if o.Resume == "" && o.Jobs == "" && len(o.JobsFiles) == 0 && len(o.defaultJobs) == 0 && o.selector == nil {
+		return errors.New("--jobs, --jobs-file, --select (or JOBS env variable) is required")
+	}
+	if o.ChunkSize < 1 {
+		return fmt.Errorf("--chunk-size must be at least 1, got %d", o.ChunkSize)
+	}
+
+	if o.Retry.MaxAttempts < 1 {
+		return fmt.Errorf("--max-attempts must be at least 1, got %d", o.Retry.MaxAttempts)
+	}
+	if o.Retry.BaseDelay < 0 || o.Retry.MaxDelay < 0 {
+		return errors.New("--retry-base-delay and --retry-max-delay can not be negative")
+	}
+	if o.MetaRPS < 0 || o.StorageRPS < 0 {
+		return errors.New("--meta-rps and --storage-rps can not be negative")
+	}
+
+	o.Auth.IdentityTokenEnv = "IDENTITY_TOKEN"
+	o.Auth.AccessTokenEnv = "ACCESS_TOKEN"
+	if o.Auth.Audience == "" {
+		o.Auth.Audience = o.MetaSvcUrl()
+	}
+	return o.Auth.Validate()
+}
+
+// flagGiven - reports whether the flag was set on the command line
+func flagGiven(fs *flag.FlagSet, name string) bool {
+	given := false
+	fs.Visit(func(f *flag.Flag) {
+		given = given || f.Name == name
+	})
+	return given
+}
+
+// envOr - reads an env variable, returning def when unset
+func envOr(key, def string) string {
+	if value, ok := os.LookupEnv(key); ok && len(value) > 0 {
+		return value
+	}
+	return def
+}
+
+// envInt - reads an int env variable, returning def when unset or invalid
+func envInt(key string, def int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok || len(value) == 0 {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		fmt.Printf("%s env variable is not a number, hence picking the default value = %d\n", key, def)
+		return def
+	}
+
+	return n
+}
+
+// printUsage - prints the list of available commands
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: break-time <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-26s %s\n", cmd.name, cmd.help)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'break-time help <command>' for the flags of a command.")
+	fmt.Fprintln(w, "PROJECT, JOBS, CMD and CHUNK_SIZE env variables are used when flags are not given.")
+	fmt.Fprintln(w, "Tokens come from the gcloud CLI unless --auth selects another credential backend.")
+}
@@ -0,0 +1,573 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/mah35h95/break-time/dice"
+	"github.com/mah35h95/break-time/journal"
+	"github.com/mah35h95/break-time/utils"
+)
+
+// runContext - state shared by every job of a run
+type runContext struct {
+	opts   options
+	client *dice.Client
+	fs     *utils.DiceFS
+	// journal - journal of the run, also keeps the job snapshots
+	journal *journal.Journal
+	// out - stdout of the process, reports are written to it
+	out io.Writer
+	// progress - every other message of the run, stderr for report commands so out only carries the report
+	progress io.Writer
+
+	finishMu sync.Mutex
+	finish   []func() error
+}
+
+// atFinish - registers fn to run once every job of the run is done, for reports over all jobs
+func (rc *runContext) atFinish(fn func() error) {
+	rc.finishMu.Lock()
+	defer rc.finishMu.Unlock()
+	rc.finish = append(rc.finish, fn)
+}
+
+// runFinish - runs the functions registered with atFinish in order
+func (rc *runContext) runFinish() error {
+	rc.finishMu.Lock()
+	defer rc.finishMu.Unlock()
+
+	errs := []error{}
+	for _, fn := range rc.finish {
+		if err := fn(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// triggered - reports a command sent for the job, or planned in dry run mode
+func (rc *runContext) triggered(id dice.DataSourceID, what string) {
+	if rc.client.DryRun() {
+		fmt.Fprintf(rc.progress, "Dry run: job %s would be triggered %s.\n", id, what)
+		return
+	}
+	fmt.Fprintf(rc.progress, "Job %s has been triggered %s.\n", id, what)
+}
+
+// jobFunc - executes a command for a single job, n is the 1 based position of the job in the run
+type jobFunc func(ctx context.Context, rc *runContext, n int, id dice.DataSourceID) error
+
+// checkFunc - validates the command flags once parsed, before the run is planned or any api is called
+type checkFunc func() error
+
+// command - a CLI subcommand backed by a dice command
+type command struct {
+	name    string
+	aliases []string
+	cmd     string
+	help    string
+	// setup - registers the command specific flags and returns the job runner along with
+	// the check of the flags, nil when there is nothing to check
+	setup func(fs *flag.FlagSet) (jobFunc, checkFunc)
+	// defaultJobs - lists the jobs to run when none are given, nil when jobs are required
+	defaultJobs func(fs *flag.FlagSet, opts options) ([]utils.JobEntry, error)
+	// report - stdout only carries the report written to rc.out, so it can be piped
+	report bool
+	// storage - the jobs call cloud storage, so access tokens are needed along with identity tokens
+	storage bool
+}
+
+// commands - every subcommand supported by break-time
+var commands = []*command{
+	simpleCommand(dice.Pause, "pause the jobs"),
+	simpleCommand(dice.Resume, "resume paused jobs"),
+	simpleCommand(dice.Stop, "stop the running loads of the jobs"),
+	simpleCommand(dice.Load, "trigger a load of the jobs"),
+	simpleCommand(dice.Lock, "lock the jobs"),
+	simpleCommand(dice.Unlock, "unlock the jobs"),
+	{
+		name:  cliName(dice.Reload),
+		cmd:   dice.Reload,
+		help:  "reload the jobs from scratch",
+		setup: setupReload,
+	},
+	{
+		name:  cliName(dice.Delete),
+		cmd:   dice.Delete,
+		help:  "delete the jobs",
+		setup: setupDelete,
+	},
+	{
+		name:  cliName(dice.DeleteHydratedRes),
+		cmd:   dice.DeleteHydratedRes,
+		help:  "delete the hydrated resources of the jobs",
+		setup: setupDeleteHydratedRes,
+	},
+	{
+		name:  cliName(dice.Edit),
+		cmd:   dice.Edit,
+		help:  "edit arbitrary fields of the jobs with sjson assignments, a JSON Patch or a merge patch",
+		setup: setupEdit,
+	},
+	{
+		name:  cliName(dice.EditCron),
+		cmd:   dice.EditCron,
+		help:  "spread the cron schedules of the jobs over time slots",
+		setup: setupEditCron,
+	},
+	{
+		name:  cliName(dice.EditGCPTarget),
+		cmd:   dice.EditGCPTarget,
+		help:  "add, remove or replace the GCP target projects and jdbc targets of the jobs",
+		setup: setupEditGCPTarget,
+	},
+	{
+		name:  cliName(dice.ToNewLake),
+		cmd:   dice.ToNewLake,
+		help:  "move the jobs to the new lake",
+		setup: setupToNewLake,
+	},
+	{
+		name:  cliName(dice.FromNewLake),
+		cmd:   dice.FromNewLake,
+		help:  "move the jobs back from the new lake",
+		setup: setupFromNewLake,
+	},
+	{
+		name:    cliName(dice.CleanFS),
+		cmd:     dice.CleanFS,
+		help:    "delete old transaction folders of the jobs from dice fs",
+		setup:   setupCleanFS,
+		storage: true,
+	},
+	{
+		name:    cliName(dice.ListCurrentFS),
+		cmd:     dice.ListCurrentFS,
+		help:    "count excess current folders of the jobs in dice fs",
+		setup:   setupListCurrentFS,
+		storage: true,
+	},
+	{
+		name:    cliName(dice.ListAllFS),
+		aliases: []string{"list-fs"},
+		cmd:     dice.ListAllFS,
+		help:    "write every dice fs folder of the jobs to a log file",
+		setup:   setupListAllFS,
+		storage: true,
+	},
+	{
+		name:   "list-jobs",
+		cmd:    "list_jobs",
+		help:   "print the jobs chosen with --select, one dataSourceId per line",
+		setup:  setupListJobs,
+		report: true,
+	},
+	{
+		name:   "status",
+		cmd:    "status",
+		help:   "print the state, lock, schedule, lake and target projects of the jobs",
+		setup:  setupStatus,
+		report: true,
+	},
+	{
+		name:   "schedule-report",
+		cmd:    "schedule_report",
+		help:   "histogram of the job cron fires per time bucket in UTC, with hotspots flagged",
+		setup:  setupScheduleReport,
+		report: true,
+	},
+	{
+		name:  playbookCmd,
+		cmd:   playbookCmd,
+		help:  "run the steps of a YAML or JSON playbook for every job, a job stops at its first failing step and continues after its last completed one when the run is resumed",
+		setup: setupPlaybook,
+	},
+	{
+		name:        "rollback",
+		cmd:         "rollback",
+		help:        "post the job definitions snapshotted by an edit run back to the edit api",
+		setup:       setupRollback,
+		defaultJobs: rollbackJobs,
+	},
+}
+
+// cliName - converts a dice command to its CLI name, eg. edit_cron => edit-cron
+func cliName(cmd string) string {
+	return strings.ReplaceAll(cmd, "_", "-")
+}
+
+// findCommand - looks up a command by its CLI name or dice command name
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name || cmd.cmd == name || slices.Contains(cmd.aliases, name) {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// simpleCommand - a command that posts an empty body to the job cmd endpoint
+func simpleCommand(cmd, help string) *command {
+	return &command{
+		name: cliName(cmd),
+		cmd:  cmd,
+		help: help,
+		setup: func(fs *flag.FlagSet) (jobFunc, checkFunc) {
+			waiter := newWaiter(fs, cmd)
+
+			return func(ctx context.Context, rc *runContext, n int, id dice.DataSourceID) error {
+				err := rc.client.ExecuteJobCmd(ctx, id, cmd, `{}`)
+				if err != nil {
+					return err
+				}
+
+				rc.triggered(id, "to be "+cmd)
+				return waiter.wait(ctx, rc, id)
+			}, waiter.check
+		},
+	}
+}
+
+func setupReload(fs *flag.FlagSet) (jobFunc, checkFunc) {
+	keepFoundryDataset := fs.Bool("keep-foundry-dataset", true, "keep the foundry dataset while reloading")
+	retainData := fs.Bool("retain-data", false, "retain the loaded data while reloading")
+	waiter := newWaiter(fs, dice.Reload)
+
+	return func(ctx context.Context, rc *runContext, n int, id dice.DataSourceID) error {
+		err := rc.client.Reload(ctx, id, dice.ReloadOptions{
+			KeepFoundryDataset: *keepFoundryDataset,
+			RetainData:         *retainData,
+		})
+		if err != nil {
+			return err
+		}
+
+		rc.triggered(id, "to be reloaded")
+		return waiter.wait(ctx, rc, id)
+	}, waiter.check
+}
+
+func setupDelete(fs *flag.FlagSet) (jobFunc, checkFunc) {
+	return func(ctx context.Context, rc *runContext, n int, id dice.DataSourceID) error {
+		if err := rc.client.Delete(ctx, id); err != nil {
+			return err
+		}
+
+		rc.triggered(id, "to be deleted")
+		return nil
+	}, nil
+}
+
+func setupDeleteHydratedRes(fs *flag.FlagSet) (jobFunc, checkFunc) {
+	return func(ctx context.Context, rc *runContext, n int, id dice.DataSourceID) error {
+		if err := rc.client.DeleteHydratedResources(ctx, id); err != nil {
+			return err
+		}
+
+		rc.triggered(id, "to clean up the hydrated resources")
+		return nil
+	}, nil
+}
+
+func setupEdit(fs *flag.FlagSet) (jobFunc, checkFunc) {
+	sets := stringsFlag{}
+	deletes := stringsFlag{}
+	fs.Var(&sets, "set", "sjson path=value assignment, value is used as json when valid, else as a string, repeatable; eg. newLakeJob=true, targetProjectIds.-1=\"qa-1\"")
+	fs.Var(&deletes, "delete", "sjson path to delete, repeatable")
+	patchFile := fs.String("patch-file", "", "RFC 6902 JSON Patch file applied to the jobs")
+	mergePatchFile := fs.String("merge-patch-file", "", "RFC 7386 JSON merge patch file applied to the jobs")
+
+	loadEdit := sync.OnceValues(func() (editFunc, error) {
+		return buildEdit(sets, deletes, *patchFile, *mergePatchFile)
+	})
+	check := func() error {
+		_, err := loadEdit()
+		return err
+	}
+
+	return func(ctx context.Context, rc *runContext, n int, id dice.DataSourceID) error {
+		edit, err := loadEdit()
+		if err != nil {
+			return err
+		}
+		return editJob(ctx, rc, id, edit)
+	}, check
+}
+
+func setupEditCron(fs *flag.FlagSet) (jobFunc, checkFunc) {
+	planner := newCronPlanner(fs)
+
+	return func(ctx context.Context, rc *runContext, n int, id dice.DataSourceID) error {
+		plan, err := planner.Plan(rc)
+		if err != nil {
+			return err
+		}
+
+		cron := plan.Crons[id.String()]
+		return editJob(ctx, rc, id, func(job []byte) (string, error) {
+			return dice.SetCronSchedule(job, cron, plan.Timezone)
+		})
+	}, planner.check
+}
+
+func setupEditGCPTarget(fs *flag.FlagSet) (jobFunc, checkFunc) {
+	projects := stringsFlag{}
+	jdbcTargets := stringsFlag{}
+	fs.Var(&projects, "target-project", "comma separated GCP target project ids, repeatable")
+	fs.Var(&jdbcTargets, "jdbc-target", "jdbc target as a json value, repeatable")
+	mode := fs.String("mode", "", "add, remove or replace the given targets of the current ones of the job (default replace)")
+	configFile := fs.String("config", "", `json file with "mode", "targetProjectIds" and "jdbcTargets", merged with the flags`)
+
+	loadEdit := sync.OnceValues(func() (editFunc, error) {
+		return buildGCPTargetEdit(*mode, projects, jdbcTargets, *configFile)
+	})
+	check := func() error {
+		_, err := loadEdit()
+		return err
+	}
+
+	return func(ctx context.Context, rc *runContext, n int, id dice.DataSourceID) error {
+		edit, err := loadEdit()
+		if err != nil {
+			return err
+		}
+		return editJob(ctx, rc, id, edit)
+	}, check
+}
+
+func setupToNewLake(fs *flag.FlagSet) (jobFunc, checkFunc) {
+	stopPrefix := fs.String(
+		"stop-prefix",
+		"bigquery-source.bigquery.prod_2434_entdataingest_05104f.",
+		"jobs with this dataSourceId prefix are stopped before moving",
+	)
+
+	return func(ctx context.Context, rc *runContext, n int, id dice.DataSourceID) error {
+		if *stopPrefix != "" && strings.HasPrefix(id.String(), *stopPrefix) {
+			if err := rc.client.Stop(ctx, id); err != nil {
+				return err
+			}
+			rc.triggered(id, "to be "+dice.Stop)
+		}
+
+		return editJob(ctx, rc, id, patch(`{"newLakeJob":true}`))
+	}, nil
+}
+
+func setupFromNewLake(fs *flag.FlagSet) (jobFunc, checkFunc) {
+	return func(ctx context.Context, rc *runContext, n int, id dice.DataSourceID) error {
+		return editJob(ctx, rc, id, patch(`{"newLakeJob":false}`))
+	}, nil
+}
+
+func setupCleanFS(fs *flag.FlagSet) (jobFunc, checkFunc) {
+	deleteChunk := fs.Int("delete-batch", 100, "number of prefixes deleted per delete_storage call")
+	check := func() error {
+		if *deleteChunk < 1 {
+			return fmt.Errorf("--delete-batch must be at least 1, got %d", *deleteChunk)
+		}
+		return nil
+	}
+
+	return func(ctx context.Context, rc *runContext, n int, id dice.DataSourceID) error {
+		dirs, err := rc.fs.GetTransactionsDirs(ctx, id)
+		if err != nil {
+			return err
+		}
+		errs := []error{}
+
+		for _, prefixes := range utils.ChunkJobs(dirs, *deleteChunk) {
+			dirDeleteReq := dice.DeleteStorageRequest{
+				Bucket:          rc.fs.Bucket,
+				StoragePrefixes: []dice.StoragePrefix{},
+			}
+
+			for _, prefix := range prefixes {
+				dirDeleteReq.StoragePrefixes = append(
+					dirDeleteReq.StoragePrefixes,
+					dice.StoragePrefix{Prefix: prefix},
+				)
+			}
+
+			err := rc.client.DeleteStorage(ctx, id, dirDeleteReq)
+			if err != nil {
+				fmt.Fprintln(rc.progress, err)
+				errs = append(errs, err)
+				continue
+			}
+
+			rc.triggered(id, "to be "+dice.DeleteStorage)
+		}
+
+		return errors.Join(errs...)
+	}, check
+}
+
+func setupListCurrentFS(fs *flag.FlagSet) (jobFunc, checkFunc) {
+	return func(ctx context.Context, rc *runContext, n int, id dice.DataSourceID) error {
+		dirs, err := rc.fs.GetCurrentDirs(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if len(dirs) > 0 {
+			fmt.Fprintf(rc.progress, "Excess (%d) folders in %s\n", len(dirs)-2, id)
+		}
+
+		return nil
+	}, nil
+}
+
+func setupListAllFS(fs *flag.FlagSet) (jobFunc, checkFunc) {
+	outDir := fs.String("out-dir", "./jobs", "directory the <dataSourceId>.log files are written to")
+
+	return func(ctx context.Context, rc *runContext, n int, id dice.DataSourceID) error {
+		dirs := []string{}
+		for _, list := range []func(context.Context, dice.DataSourceID) ([]string, error){
+			rc.fs.GetTransactionsDirs,
+			rc.fs.GetCurrentDirs,
+			rc.fs.GetDeltaDirs,
+		} {
+			listed, err := list(ctx, id)
+			if err != nil {
+				return err
+			}
+			dirs = append(dirs, listed...)
+		}
+
+		if len(dirs) > 0 {
+			err := utils.WriteToFile(
+				filepath.Join(*outDir, id.String()+".log"),
+				[]byte(strings.Join(dirs, "\n")+"\n"),
+			)
+			if err != nil {
+				return fmt.Errorf("error writing file: %v", err)
+			}
+			fmt.Fprintf(rc.progress, "Data written successfully for %s\n", id)
+		}
+
+		return nil
+	}, nil
+}
+
+func setupListJobs(fs *flag.FlagSet) (jobFunc, checkFunc) {
+	out := fs.String("out", "", "also write the jobs to this file, usable with --jobs-file")
+
+	once := sync.Once{}
+	mu := sync.Mutex{}
+	jobs := map[int]string{}
+
+	return func(ctx context.Context, rc *runContext, n int, id dice.DataSourceID) error {
+		if *out != "" {
+			once.Do(func() {
+				rc.atFinish(func() error {
+					mu.Lock()
+					defer mu.Unlock()
+
+					positions := []int{}
+					for n := range jobs {
+						positions = append(positions, n)
+					}
+					slices.Sort(positions)
+
+					lines := make([]string, 0, len(jobs))
+					for _, n := range positions {
+						lines = append(lines, jobs[n])
+					}
+					return utils.WriteToFile(*out, []byte(strings.Join(lines, "\n")+"\n"))
+				})
+			})
+		}
+
+		mu.Lock()
+		jobs[n] = id.String()
+		mu.Unlock()
+
+		fmt.Fprintln(rc.out, id)
+		return nil
+	}, nil
+}
+
+func setupRollback(fs *flag.FlagSet) (jobFunc, checkFunc) {
+	runID := fs.String("run", "", "id of the run whose snapshots are posted back, required")
+	check := func() error {
+		if *runID == "" {
+			return errors.New("--run is required")
+		}
+		return nil
+	}
+
+	return func(ctx context.Context, rc *runContext, n int, id dice.DataSourceID) error {
+		snapshot, err := journal.ReadSnapshot(rc.opts.RunsDir, *runID, id.String())
+		if err != nil {
+			return err
+		}
+
+		return editJob(ctx, rc, id, patch(string(snapshot)))
+	}, check
+}
+
+// rollbackJobs - lists every job snapshotted by the --run of rollback
+func rollbackJobs(fs *flag.FlagSet, opts options) ([]utils.JobEntry, error) {
+	runID := fs.Lookup("run").Value.String()
+	if runID == "" {
+		return nil, errors.New("--run is required")
+	}
+
+	jobs, err := journal.SnapshotJobs(opts.RunsDir, runID)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("run %s has no snapshots", runID)
+	}
+
+	entries := make([]utils.JobEntry, len(jobs))
+	for i, job := range jobs {
+		entries[i] = utils.JobEntry{ID: job, Source: "snapshots of run " + runID}
+	}
+	return entries, nil
+}
+
+// editFunc - returns the body posted to the edit api from the current job definition
+type editFunc func(job []byte) (string, error)
+
+// patch - an editFunc posting the given body whatever the job definition is
+func patch(body string) editFunc {
+	return func(job []byte) (string, error) {
+		return body, nil
+	}
+}
+
+// editJob - snapshots the job definition, then posts the body built by edit to the edit api of the job
+func editJob(ctx context.Context, rc *runContext, id dice.DataSourceID, edit editFunc) error {
+	fmt.Fprintf(rc.progress, "Getting job data of %s\n", id)
+	job, err := rc.client.GetJob(ctx, id)
+	if err != nil {
+		return fmt.Errorf("get job: %v", err)
+	}
+
+	if _, err := rc.journal.SaveSnapshot(id.String(), job); err != nil {
+		return err
+	}
+
+	body, err := edit(job)
+	if err != nil {
+		return err
+	}
+
+	if err := rc.client.Edit(ctx, id, body); err != nil {
+		return err
+	}
+
+	rc.triggered(id, "to be "+dice.Edit)
+	return nil
+}
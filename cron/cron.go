@@ -0,0 +1,285 @@
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidExpression - returned (wrapped) for expressions that can not be parsed
+var ErrInvalidExpression = errors.New("invalid cron expression")
+
+// maxSearch - how far Next looks ahead before giving up on expressions like 0 0 30 2 *
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+// macros - shorthands accepted in place of the 5 fields
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// field - bounds and names of a cron field
+type field struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{
+		name: "month", min: 1, max: 12,
+		names: []string{"", "jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"},
+	}
+	// dowField - 7 is accepted for sunday and folded into 0
+	dowField = field{
+		name: "day of week", min: 0, max: 7,
+		names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"},
+	}
+)
+
+// Schedule - a parsed standard 5 field cron expression,
+// <minute> <hour> <day of month> <month> <day of week>
+type Schedule struct {
+	expr string
+
+	minute, hour, dom, month, dow uint64
+	// domAny, dowAny - the field starts with '*', when both days are restricted a day matching either runs
+	domAny, dowAny bool
+	// hourAny - the hour field starts with '*', such schedules also fire in a repeated hour
+	hourAny bool
+}
+
+// Parse - parses a 5 field cron expression or one of the @daily like macros
+//
+// Fields accept '*', numbers, names of months and weekdays, ranges, lists and steps, eg. */15 or mon-fri.
+func Parse(expr string) (*Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) == 1 {
+		if macro, ok := macros[strings.ToLower(fields[0])]; ok {
+			fields = strings.Fields(macro)
+		}
+	}
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w %q: expected 5 fields, got %d", ErrInvalidExpression, expr, len(fields))
+	}
+
+	s := &Schedule{
+		expr:    strings.Join(fields, " "),
+		domAny:  strings.HasPrefix(fields[2], "*") || fields[2] == "?",
+		dowAny:  strings.HasPrefix(fields[4], "*") || fields[4] == "?",
+		hourAny: strings.HasPrefix(fields[1], "*"),
+	}
+
+	errs := []error{}
+	for _, f := range []struct {
+		bits  *uint64
+		value string
+		field field
+	}{
+		{&s.minute, fields[0], minuteField},
+		{&s.hour, fields[1], hourField},
+		{&s.dom, fields[2], domField},
+		{&s.month, fields[3], monthField},
+		{&s.dow, fields[4], dowField},
+	} {
+		bits, err := f.field.parse(f.value)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		*f.bits = bits
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%w %q: %v", ErrInvalidExpression, expr, errors.Join(errs...))
+	}
+
+	if s.dow&(1<<7) != 0 {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+
+	return s, nil
+}
+
+// Validate - reports whether the expression parses
+func Validate(expr string) error {
+	_, err := Parse(expr)
+	return err
+}
+
+// LoadTimezone - loads an IANA timezone, rejecting empty and Local as they depend on the machine
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("invalid timezone %q: an IANA timezone like America/Chicago is required", name)
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %v", name, err)
+	}
+	return loc, nil
+}
+
+// String - returns the normalized expression
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// parse - returns the bit set of the values matched by a field
+func (f field) parse(value string) (uint64, error) {
+	if value == "" {
+		return 0, fmt.Errorf("%s is empty", f.name)
+	}
+
+	var bits uint64
+	for _, part := range strings.Split(value, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("%s: invalid step %q", f.name, stepPart)
+			}
+			step = n
+		}
+
+		first, last := f.min, f.max
+		switch {
+		case rangePart == "*" || rangePart == "?":
+			if f.max == 7 {
+				last = 6
+			}
+		case strings.Contains(rangePart, "-"):
+			from, to, _ := strings.Cut(rangePart, "-")
+			var err error
+			if first, err = f.value(from); err != nil {
+				return 0, err
+			}
+			if last, err = f.value(to); err != nil {
+				return 0, err
+			}
+			if last < first {
+				return 0, fmt.Errorf("%s: range %q ends before it starts", f.name, rangePart)
+			}
+		default:
+			var err error
+			if first, err = f.value(rangePart); err != nil {
+				return 0, err
+			}
+			// a/n runs from a to the end of the field, a alone only at a
+			if !hasStep {
+				last = first
+			}
+		}
+
+		for v := first; v <= last; v += step {
+			bits |= 1 << v
+		}
+	}
+
+	return bits, nil
+}
+
+// value - parses a number or name of the field
+func (f field) value(s string) (int, error) {
+	lower := strings.ToLower(s)
+	for i, name := range f.names {
+		if name != "" && name == lower {
+			return i, nil
+		}
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid value %q", f.name, s)
+	}
+	if n < f.min || n > f.max {
+		return 0, fmt.Errorf("%s: %d out of range %d-%d", f.name, n, f.min, f.max)
+	}
+	return n, nil
+}
+
+// Next - returns the first fire time after t in the timezone of t, the zero time when
+// the expression never fires, eg. 0 0 30 2 *
+//
+// Like cron, times skipped when the clocks go forward do not fire, and times repeated when
+// the clocks go back fire once, unless the hour field is '*' and the schedule fires every hour.
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxSearch)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			if !next.After(t) {
+				// the wall clock went back, step over the repeated hour
+				next = t.Truncate(time.Hour).Add(time.Hour)
+			}
+			t = next
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 || (!s.hourAny && repeated(t)) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+// repeated - reports whether the wall clock time of t already occurred earlier the same day,
+// in the hour repeated when the clocks go back
+func repeated(t time.Time) bool {
+	_, offset := t.Zone()
+	_, dayBefore := t.Add(-24 * time.Hour).Zone()
+	if dayBefore <= offset {
+		return false
+	}
+
+	earlier := t.Add(-time.Duration(dayBefore-offset) * time.Second)
+	return earlier.Day() == t.Day() && earlier.Hour() == t.Hour() && earlier.Minute() == t.Minute()
+}
+
+// NextN - returns the next n fire times after t, fewer when the expression stops firing
+func (s *Schedule) NextN(t time.Time, n int) []time.Time {
+	times := []time.Time{}
+	for range n {
+		t = s.Next(t)
+		if t.IsZero() {
+			break
+		}
+		times = append(times, t)
+	}
+	return times
+}
+
+// dayMatches - checks the day of month and day of week fields, either one when both are restricted
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+
+	if s.domAny || s.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
@@ -0,0 +1,269 @@
+package cron
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := LoadTimezone(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestNextN(t *testing.T) {
+	chicago := mustLoad(t, "America/Chicago")
+	cdt := time.FixedZone("CDT", -5*60*60)
+	cst := time.FixedZone("CST", -6*60*60)
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want []time.Time
+	}{
+		{
+			name: "steps and ranges",
+			expr: "*/20 1-5/2 * * *",
+			from: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			want: []time.Time{
+				time.Date(2026, 1, 1, 1, 0, 0, 0, time.UTC),
+				time.Date(2026, 1, 1, 1, 20, 0, 0, time.UTC),
+				time.Date(2026, 1, 1, 1, 40, 0, 0, time.UTC),
+				time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "start with step runs to the end of the field",
+			expr: "50/5 0 * * *",
+			from: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			want: []time.Time{
+				time.Date(2026, 1, 1, 0, 50, 0, 0, time.UTC),
+				time.Date(2026, 1, 1, 0, 55, 0, 0, time.UTC),
+				time.Date(2026, 1, 2, 0, 50, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "lists",
+			expr: "0 6,18 1,15 * *",
+			from: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC),
+			want: []time.Time{
+				time.Date(2026, 1, 1, 18, 0, 0, 0, time.UTC),
+				time.Date(2026, 1, 15, 6, 0, 0, 0, time.UTC),
+				time.Date(2026, 1, 15, 18, 0, 0, 0, time.UTC),
+				time.Date(2026, 2, 1, 6, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "month and weekday names",
+			expr: "0 9 * JAN,jul Mon-Wed",
+			from: time.Date(2026, 1, 30, 0, 0, 0, 0, time.UTC), // friday
+			want: []time.Time{
+				time.Date(2026, 7, 1, 9, 0, 0, 0, time.UTC), // wednesday
+				time.Date(2026, 7, 6, 9, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "7 is sunday",
+			expr: "0 0 * * 7",
+			from: time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC), // wednesday
+			want: []time.Time{
+				time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "range ending on 7 includes sunday",
+			expr: "0 0 * * 5-7",
+			from: time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC),
+			want: []time.Time{
+				time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 10, 23, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "restricted day of month and day of week fire on either",
+			expr: "0 0 13 * fri",
+			from: time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC),
+			want: []time.Time{
+				time.Date(2026, 11, 6, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 11, 13, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 11, 20, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 11, 27, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 12, 4, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 12, 11, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 12, 13, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "day of month with any weekday fires on the day only",
+			expr: "0 0 13 * *",
+			from: time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC),
+			want: []time.Time{
+				time.Date(2026, 11, 13, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 12, 13, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			// like vixie cron, a field starting with '*' counts as unrestricted for the either rule
+			name: "stepped star day of week has to match along with the day of month",
+			expr: "0 0 1 * */2",
+			from: time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC),
+			want: []time.Time{
+				time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC), // tuesday
+				time.Date(2027, 4, 1, 0, 0, 0, 0, time.UTC),  // thursday
+				time.Date(2027, 5, 1, 0, 0, 0, 0, time.UTC),  // saturday
+			},
+		},
+		{
+			name: "macro",
+			expr: "@daily",
+			from: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			want: []time.Time{
+				time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "leap day",
+			expr: "0 0 29 2 *",
+			from: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			want: []time.Time{
+				time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+				time.Date(2032, 2, 29, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "never fires",
+			expr: "0 0 30 2 *",
+			from: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			want: []time.Time{},
+		},
+		{
+			name: "never fires in a 30 day month",
+			expr: "0 0 31 apr,jun,sep,nov *",
+			from: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			want: []time.Time{},
+		},
+		{
+			name: "time skipped when the clocks go forward does not fire",
+			expr: "30 2 * * *",
+			from: time.Date(2026, 3, 7, 12, 0, 0, 0, chicago),
+			want: []time.Time{
+				time.Date(2026, 3, 9, 2, 30, 0, 0, cdt),
+			},
+		},
+		{
+			name: "time repeated when the clocks go back fires once",
+			expr: "30 1 * * *",
+			from: time.Date(2026, 10, 31, 12, 0, 0, 0, chicago),
+			want: []time.Time{
+				time.Date(2026, 11, 1, 1, 30, 0, 0, cdt),
+				time.Date(2026, 11, 2, 1, 30, 0, 0, cst),
+			},
+		},
+		{
+			name: "every minute of a fixed repeated hour fires once",
+			expr: "*/30 1 * * *",
+			from: time.Date(2026, 11, 1, 0, 0, 0, 0, chicago),
+			want: []time.Time{
+				time.Date(2026, 11, 1, 1, 0, 0, 0, cdt),
+				time.Date(2026, 11, 1, 1, 30, 0, 0, cdt),
+				time.Date(2026, 11, 2, 1, 0, 0, 0, cst),
+			},
+		},
+		{
+			name: "hourly schedules fire in both repeated hours",
+			expr: "30 * * * *",
+			from: time.Date(2026, 11, 1, 0, 0, 0, 0, chicago),
+			want: []time.Time{
+				time.Date(2026, 11, 1, 0, 30, 0, 0, cdt),
+				time.Date(2026, 11, 1, 1, 30, 0, 0, cdt),
+				time.Date(2026, 11, 1, 1, 30, 0, 0, cst),
+				time.Date(2026, 11, 1, 2, 30, 0, 0, cst),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.expr, err)
+			}
+
+			n := len(tt.want)
+			if n == 0 {
+				n = 1
+			}
+			got := s.NextN(tt.from, n)
+			if len(got) != len(tt.want) {
+				t.Fatalf("NextN(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("NextN(%q)[%d] = %s, want %s", tt.expr, i, got[i], tt.want[i])
+				}
+				if got[i].Location() != tt.from.Location() {
+					t.Errorf("NextN(%q)[%d] is in %s, want %s", tt.expr, i, got[i].Location(), tt.from.Location())
+				}
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"@every",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"1,,2 * * * *",
+		"* * * foo *",
+		"* * * * mon-",
+	} {
+		if _, err := Parse(expr); !errors.Is(err, ErrInvalidExpression) {
+			t.Errorf("Parse(%q) = %v, want ErrInvalidExpression", expr, err)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	for expr, want := range map[string]string{
+		"  0   6 * *  mon-fri ": "0 6 * * mon-fri",
+		"@hourly":               "0 * * * *",
+	} {
+		s, err := Parse(expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", expr, err)
+		}
+		if s.String() != want {
+			t.Errorf("Parse(%q).String() = %q, want %q", expr, s.String(), want)
+		}
+	}
+}
+
+func TestLoadTimezone(t *testing.T) {
+	for _, name := range []string{"", "Local", "Mars/Base"} {
+		if _, err := LoadTimezone(name); err == nil {
+			t.Errorf("LoadTimezone(%q) succeeded, want an error", name)
+		}
+	}
+	if _, err := LoadTimezone("UTC"); err != nil {
+		t.Errorf("LoadTimezone(UTC): %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mah35h95/break-time/cron"
+	"github.com/mah35h95/break-time/schedule"
+)
+
+// cronPlanFile - csv of the job crons of an edit-cron run, inside the run dir
+const cronPlanFile = "cron-plan.csv"
+
+// cronPlanner - builds the cron plan of an edit-cron run once, from every job of the run
+type cronPlanner struct {
+	start, end string
+	spread     schedule.Spread
+	// cron - fixed expression of every job, the spread is not used when set
+	cron    string
+	preview int
+
+	once sync.Once
+	plan *schedule.Plan
+	err  error
+}
+
+// newCronPlanner - registers the spreading flags of edit-cron
+//
+// The defaults spread 50 jobs per 30 minute slot from midnight to 09:00 Chicago time.
+func newCronPlanner(fs *flag.FlagSet) *cronPlanner {
+	p := &cronPlanner{}
+	fs.StringVar(&p.start, "start", "00:00", "time of day of the first slot, HH:MM")
+	fs.StringVar(&p.end, "end", "09:00", "time of day no slot starts at or after, HH:MM, before --start to wrap over midnight")
+	fs.DurationVar(&p.spread.Interval, "interval", 30*time.Minute, "time between slots, whole minutes")
+	fs.IntVar(&p.spread.PerSlot, "per-slot", 50, "max jobs per slot, 0 spreads the jobs evenly over every slot")
+	fs.StringVar(&p.spread.Timezone, "timezone", "America/Chicago", "IANA timezone of the cron schedule")
+	fs.StringVar(&p.spread.Weekdays, "days", "", "days of week the jobs run on, eg. mon-fri, every day when empty")
+	fs.StringVar(&p.spread.Assign, "assign", schedule.AssignHash, "hash: slot picked from the job id, stable across reruns; order: slots filled in job list order")
+	fs.StringVar(&p.cron, "cron", "", "5 field cron expression given to every job instead of spreading them, eg. '0 6 * * mon-fri'")
+	fs.IntVar(&p.preview, "preview", 3, "number of next fire times printed per slot")
+	return p
+}
+
+// check - validates the flags, --start and --end are parsed into the spread
+func (p *cronPlanner) check() error {
+	if p.cron != "" {
+		errs := []error{}
+		if err := cron.Validate(p.cron); err != nil {
+			errs = append(errs, fmt.Errorf("--cron: %v", err))
+		}
+		if _, err := cron.LoadTimezone(p.spread.Timezone); err != nil {
+			errs = append(errs, fmt.Errorf("--timezone: %v", err))
+		}
+		return errors.Join(errs...)
+	}
+
+	var err error
+	if p.spread.Start, err = schedule.ParseTimeOfDay(p.start); err != nil {
+		return fmt.Errorf("--start: %v", err)
+	}
+	if p.spread.End, err = schedule.ParseTimeOfDay(p.end); err != nil {
+		return fmt.Errorf("--end: %v", err)
+	}
+	return p.spread.Validate()
+}
+
+// Plan - returns the plan of the run, built and printed on the first call
+//
+// Every job of the run is planned, also the ones a resumed run already completed,
+// so a resumed run assigns the same crons as the original one.
+func (p *cronPlanner) Plan(rc *runContext) (*schedule.Plan, error) {
+	p.once.Do(func() {
+		p.plan, p.err = p.build(rc)
+	})
+	return p.plan, p.err
+}
+
+// build - plans the jobs of the run and writes the plan to the run dir
+func (p *cronPlanner) build(rc *runContext) (*schedule.Plan, error) {
+	jobs := rc.journal.Run().Jobs
+	if p.cron != "" {
+		plan, err := schedule.Fixed(p.cron, p.spread.Timezone, jobs)
+		if err != nil {
+			return nil, err
+		}
+		return plan, p.write(rc, plan)
+	}
+
+	plan, err := p.spread.Plan(jobs)
+	if err != nil {
+		return nil, err
+	}
+	return plan, p.write(rc, plan)
+}
+
+// write - prints the plan and writes the cron of every job to the run dir
+func (p *cronPlanner) write(rc *runContext, plan *schedule.Plan) error {
+	plan.Print(rc.progress, p.preview)
+
+	lines := []string{"job,cron,timezone"}
+	for _, job := range rc.journal.Run().Jobs {
+		lines = append(lines, fmt.Sprintf("%s,%s,%s", job, plan.Crons[job], plan.Timezone))
+	}
+	path := filepath.Join(rc.journal.Dir(), cronPlanFile)
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("write cron plan: %v", err)
+	}
+	fmt.Fprintf(rc.progress, "Cron of every job: %s\n", path)
+
+	return nil
+}
@@ -1,12 +1,10 @@
 package dice
 
 import (
-	"bytes"
-	"errors"
+	"context"
+	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
-	"strings"
 
 	"github.com/tidwall/sjson"
 )
@@ -32,249 +30,156 @@ const (
 	FromNewLake       string = "from_new_lake"
 )
 
-// ValidateToken - validates token with dice meta api
-func ValidateToken(metaSvcUrl, bearer string) error {
-	path := fmt.Sprintf("%v/", metaSvcUrl)
+// ReloadOptions - request body for the dice reload api
+type ReloadOptions struct {
+	KeepFoundryDataset bool `json:"keepFoundryDataset"`
+	RetainData         bool `json:"retainData"`
+}
 
-	request, err := http.NewRequest(http.MethodGet, path, nil)
-	if err != nil {
-		return fmt.Errorf("http.NewRequest: %v", err)
-	}
+// DeleteStorageRequest - request body for the dice delete_storage api
+type DeleteStorageRequest struct {
+	Bucket          string          `json:"bucket"`
+	StoragePrefixes []StoragePrefix `json:"storagePrefixes"`
+}
 
-	request.Header = http.Header{
-		"Authorization": {bearer},
-		"Content-Type":  {"application/json"},
-		"tyson-user":    {"qtpie"},
-	}
+// StoragePrefix - a single GCS prefix to be deleted
+type StoragePrefix struct {
+	Prefix string `json:"prefix"`
+}
 
-	// Send req using http Client
-	client := &http.Client{}
-	response, err := client.Do(request)
+// ValidateToken - validates token with dice meta api
+func (c *Client) ValidateToken(ctx context.Context) error {
+	_, err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   "/",
+		header: http.Header{"Tyson-User": {"qtpie"}},
+	})
 	if err != nil {
-		return fmt.Errorf("client.Do: %v", err)
+		fmt.Fprintln(c.log, "Token is Invalid")
+		return err
 	}
-	if response.StatusCode == 403 {
-		fmt.Println("Token is Invalid")
-		return fmt.Errorf("403")
-	}
-	defer response.Body.Close()
 
-	fmt.Println("Token is Valid")
+	fmt.Fprintln(c.log, "Token is Valid")
 	return nil
 }
 
-// ExecuteJobCmd - Executes the dice api call for the give http method, cmd and body
-func ExecuteJobCmd(dataSourceId, metaSvcUrl, bearer, httpMethod, cmd, stringBody string) error {
-	parts := strings.Split(dataSourceId, ".")
-	if len(parts) != 5 {
-		return errors.New("invalid dataSourceId " + dataSourceId)
-	}
-
-	path := fmt.Sprintf(
-		"%s/sources/%s/technologies/%s/databases/%s/jobs/%s.%s/%s",
-		metaSvcUrl,
-		parts[0],
-		parts[1],
-		parts[2],
-		parts[3],
-		parts[4],
-		cmd,
-	)
-
-	body := strings.NewReader(stringBody)
-
-	request, err := http.NewRequest(httpMethod, path, body)
-	if err != nil {
-		return fmt.Errorf("http.NewRequest: %v", err)
-	}
-
-	request.Header = http.Header{
-		"Authorization": {bearer},
-		"Content-Type":  {"application/json"},
-	}
-
-	// Send req using http Client
-	client := &http.Client{}
-	response, err := client.Do(request)
-	if err != nil {
-		return fmt.Errorf("client.Do: %v", err)
-	}
-	if response.StatusCode == 403 {
-		return fmt.Errorf("403")
-	}
-	defer response.Body.Close()
-
-	fmt.Printf("Job %s has been triggered to be %s. Status: %d\n", dataSourceId, cmd, response.StatusCode)
-
-	return nil
+// Pause - pauses the job
+func (c *Client) Pause(ctx context.Context, id DataSourceID) error {
+	return c.ExecuteJobCmd(ctx, id, Pause, `{}`)
 }
 
-// DeleteJob - Deletes DICE job
-func DeleteJob(dataSourceId string, metaSvcUrl string, bearer string) error {
-	parts := strings.Split(dataSourceId, ".")
-	if len(parts) != 5 {
-		return errors.New("invalid dataSourceId " + dataSourceId)
-	}
+// Resume - resumes a paused job
+func (c *Client) Resume(ctx context.Context, id DataSourceID) error {
+	return c.ExecuteJobCmd(ctx, id, Resume, `{}`)
+}
 
-	path := fmt.Sprintf(
-		"%s/sources/%s/technologies/%s/databases/%s/jobs/%s.%s",
-		metaSvcUrl,
-		parts[0],
-		parts[1],
-		parts[2],
-		parts[3],
-		parts[4],
-	)
+// Stop - stops the running load of the job
+func (c *Client) Stop(ctx context.Context, id DataSourceID) error {
+	return c.ExecuteJobCmd(ctx, id, Stop, `{}`)
+}
 
-	body := strings.NewReader(`{}`)
+// Load - triggers a load of the job
+func (c *Client) Load(ctx context.Context, id DataSourceID) error {
+	return c.ExecuteJobCmd(ctx, id, Load, `{}`)
+}
 
-	request, err := http.NewRequest(http.MethodDelete, path, body)
-	if err != nil {
-		return fmt.Errorf("http.NewRequest: %v", err)
-	}
+// Lock - locks the job
+func (c *Client) Lock(ctx context.Context, id DataSourceID) error {
+	return c.ExecuteJobCmd(ctx, id, Lock, `{}`)
+}
 
-	request.Header = http.Header{
-		"Authorization": {bearer},
-		"Content-Type":  {"application/json"},
-	}
+// Unlock - unlocks the job
+func (c *Client) Unlock(ctx context.Context, id DataSourceID) error {
+	return c.ExecuteJobCmd(ctx, id, Unlock, `{}`)
+}
 
-	// Send req using http Client
-	client := &http.Client{}
-	response, err := client.Do(request)
+// Reload - reloads the job from scratch
+func (c *Client) Reload(ctx context.Context, id DataSourceID, opts ReloadOptions) error {
+	body, err := json.Marshal(opts)
 	if err != nil {
-		return fmt.Errorf("client.Do: %v", err)
-	}
-	if response.StatusCode == 403 {
-		return fmt.Errorf("403")
+		return fmt.Errorf("json.Marshal: %v", err)
 	}
-	defer response.Body.Close()
-
-	fmt.Printf("Job %s has been triggered to be deleted. Status: %d\n", dataSourceId, response.StatusCode)
-
-	return nil
+	return c.ExecuteJobCmd(ctx, id, Reload, string(body))
 }
 
-// EditCronSchedule - edits jobs to have the given cron schedule
-func EditCronSchedule(dataSourceId, metaSvcUrl, bearer, cron, cronTimeZone string) error {
-	parts := strings.Split(dataSourceId, ".")
-	if len(parts) != 5 {
-		return errors.New("invalid dataSourceId " + dataSourceId)
-	}
-
-	path := fmt.Sprintf("%s/sources/%s/technologies/%s/databases/%s/jobs/%s.%s",
-		metaSvcUrl,
-		parts[0],
-		parts[1],
-		parts[2],
-		parts[3],
-		parts[4],
-	)
-
-	client := &http.Client{}
-
-	fmt.Printf("Getting job data of %s\n", dataSourceId)
+// Edit - posts the given patch to the edit api of the job
+func (c *Client) Edit(ctx context.Context, id DataSourceID, patch string) error {
+	return c.ExecuteJobCmd(ctx, id, Edit, patch)
+}
 
-	request1, err := http.NewRequest(http.MethodGet, path, nil)
-	if err != nil {
-		return fmt.Errorf("http.NewRequest get: %v", err)
-	}
+// DeleteHydratedResources - Deletes Hydrated tables
+func (c *Client) DeleteHydratedResources(ctx context.Context, id DataSourceID) error {
+	return c.ExecuteJobCmd(ctx, id, DeleteHydratedRes, `{}`)
+}
 
-	request1.Header = http.Header{
-		"Authorization": {bearer},
-	}
-	response1, err := client.Do(request1)
+// DeleteStorage - deletes the given dice fs prefixes of the job
+func (c *Client) DeleteStorage(ctx context.Context, id DataSourceID, req DeleteStorageRequest) error {
+	body, err := json.Marshal(req)
 	if err != nil {
-		return fmt.Errorf("client.Do get: %v", err)
-	}
-	if response1.StatusCode == 403 {
-		return fmt.Errorf("403")
+		return fmt.Errorf("json.Marshal: %v", err)
 	}
+	return c.ExecuteJobCmd(ctx, id, DeleteStorage, string(body))
+}
 
-	body, err := io.ReadAll(response1.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response body. %v", err)
-	}
-	defer response1.Body.Close()
+// ExecuteJobCmd - Executes the dice api call for the given cmd and body
+func (c *Client) ExecuteJobCmd(ctx context.Context, id DataSourceID, cmd, body string) error {
+	_, err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   id.MetaPath() + "/" + cmd,
+		body:   body,
+		cmd:    cmd,
+		id:     &id,
+	})
+	return err
+}
 
-	newScheduleValue, err := sjson.Set(string(body), "schedule", cron)
-	if err != nil {
-		return fmt.Errorf("failed to update json value. %v", err)
-	}
+// Delete - Deletes DICE job
+func (c *Client) Delete(ctx context.Context, id DataSourceID) error {
+	_, err := c.do(ctx, request{
+		method: http.MethodDelete,
+		path:   id.MetaPath(),
+		body:   `{}`,
+		cmd:    Delete,
+		id:     &id,
+	})
+	return err
+}
 
-	newScheduleTimeZone, err := sjson.Set(newScheduleValue, "cronTimezone", cronTimeZone)
-	if err != nil {
-		return fmt.Errorf("failed to update json value. %v", err)
-	}
+// GetJob - returns the job definition as raw json
+func (c *Client) GetJob(ctx context.Context, id DataSourceID) ([]byte, error) {
+	return c.do(ctx, request{
+		method: http.MethodGet,
+		path:   id.MetaPath(),
+		id:     &id,
+	})
+}
 
-	request2, err := http.NewRequest(http.MethodPost, path+"/edit", bytes.NewBuffer([]byte(newScheduleTimeZone)))
+// EditCronSchedule - edits jobs to have the given cron schedule
+func (c *Client) EditCronSchedule(ctx context.Context, id DataSourceID, cron, cronTimeZone string) error {
+	body, err := c.GetJob(ctx, id)
 	if err != nil {
-		return fmt.Errorf("http.NewRequest post: %v", err)
+		return fmt.Errorf("get job: %v", err)
 	}
 
-	request2.Header = http.Header{
-		"Authorization": {bearer},
-		"Content-Type":  {"application/json"},
-		"Accept":        {"*/*"},
-	}
-	response2, err := client.Do(request2)
+	newJob, err := SetCronSchedule(body, cron, cronTimeZone)
 	if err != nil {
-		return fmt.Errorf("client.Do post: %v", err)
+		return err
 	}
-	if response2.StatusCode == 403 {
-		return fmt.Errorf("403")
-	}
-	defer response2.Body.Close()
-
-	fmt.Printf("Job %s cron has been triggered to be changed.\n", dataSourceId)
 
-	return nil
+	return c.Edit(ctx, id, newJob)
 }
 
-// DeleteHydratedResources - Deletes Hydrated tables
-func DeleteHydratedResources(dataSourceId string, metaSvcUrl string, bearer string) error {
-	parts := strings.Split(dataSourceId, ".")
-	if len(parts) != 5 {
-		return errors.New("invalid dataSourceId " + dataSourceId)
-	}
-
-	path := fmt.Sprintf(
-		"%s/sources/%s/technologies/%s/databases/%s/jobs/%s.%s/delete_hydrated_resources",
-		metaSvcUrl,
-		parts[0],
-		parts[1],
-		parts[2],
-		parts[3],
-		parts[4],
-	)
-
-	body := strings.NewReader(`{}`)
-
-	request, err := http.NewRequest(http.MethodPost, path, body)
+// SetCronSchedule - returns the job definition with the given cron schedule and timezone
+func SetCronSchedule(job []byte, cron, cronTimeZone string) (string, error) {
+	newScheduleValue, err := sjson.Set(string(job), "schedule", cron)
 	if err != nil {
-		return fmt.Errorf("http.NewRequest: %v", err)
-	}
-
-	request.Header = http.Header{
-		"Authorization": {bearer},
-		"Content-Type":  {"application/json"},
+		return "", fmt.Errorf("failed to update json value. %v", err)
 	}
 
-	// Send req using http Client
-	client := &http.Client{}
-	response, err := client.Do(request)
+	newScheduleTimeZone, err := sjson.Set(newScheduleValue, "cronTimezone", cronTimeZone)
 	if err != nil {
-		return fmt.Errorf("client.Do: %v", err)
-	}
-	if response.StatusCode == 403 {
-		return fmt.Errorf("403")
+		return "", fmt.Errorf("failed to update json value. %v", err)
 	}
-	defer response.Body.Close()
 
-	fmt.Printf(
-		"Job %s has been triggered to clean up the hydrated resources. Status: %d\n",
-		dataSourceId,
-		response.StatusCode,
-	)
-
-	return nil
+	return newScheduleTimeZone, nil
 }
@@ -0,0 +1,320 @@
+package dice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/mah35h95/break-time/transport"
+)
+
+const (
+	// DefaultUserAgent - user agent sent when none is configured
+	DefaultUserAgent = "break-time"
+	// DefaultMaxTokenRefreshes - token refreshes allowed per client when none is configured
+	DefaultMaxTokenRefreshes = 10
+)
+
+// ErrTokenRefreshLimit - returned once the client used up its token refreshes
+var ErrTokenRefreshLimit = errors.New("token refresh limit reached")
+
+// RetrySafeCmds - POST commands that end in the same state when sent twice, so they
+// are retried on transient failures, other POST commands are sent once
+var RetrySafeCmds = map[string]bool{
+	Pause:         true,
+	Resume:        true,
+	Stop:          true,
+	Lock:          true,
+	Unlock:        true,
+	Edit:          true,
+	DeleteStorage: true,
+}
+
+// TokenSource - supplies the bearer token sent to the dice meta service
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// RefreshableTokenSource - a TokenSource that can be forced to fetch a new token
+//
+// The client refreshes the token once and replays the request when the meta service answers 401 or 403.
+type RefreshableTokenSource interface {
+	TokenSource
+	Refresh() (string, error)
+}
+
+// TokenFunc - adapts a plain function to a TokenSource
+type TokenFunc func() (string, error)
+
+// Token - calls f
+func (f TokenFunc) Token() (string, error) {
+	return f()
+}
+
+// Config - everything needed to build a Client
+type Config struct {
+	// BaseURL - dice meta service url, eg. https://dice-meta-svc-dot-<project>.appspot.com
+	BaseURL string
+	// Tokens - source of the identity token used as bearer
+	Tokens TokenSource
+	// HTTPClient - client used to send requests, a client retrying with Retry when nil
+	//
+	// Retries only happen when its transport is wrapped with transport.Retrying.
+	HTTPClient *http.Client
+	// UserAgent - User-Agent header value, DefaultUserAgent when empty
+	UserAgent string
+	// MaxTokenRefreshes - caps the token refreshes over the lifetime of the client,
+	// DefaultMaxTokenRefreshes when 0 and no refresh at all when negative
+	MaxTokenRefreshes int
+	// Retry - retry policy of every request, transport.DefaultRetryPolicy when MaxAttempts is 0
+	Retry transport.RetryPolicy
+	// CmdRetry - overrides Retry for the given dice commands
+	CmdRetry map[string]transport.RetryPolicy
+	// SafeCmds - POST commands that may be retried, RetrySafeCmds when nil
+	SafeCmds map[string]bool
+	// DryRun - when set, requests other than GETs are written to it as PlannedRequest
+	// json lines instead of being sent, GETs are still sent as they change nothing
+	DryRun io.Writer
+	// Log - token refreshes and retries are reported to it, os.Stdout when nil
+	Log io.Writer
+}
+
+// PlannedRequest - a request written instead of sent in dry run mode
+type PlannedRequest struct {
+	Method string          `json:"method"`
+	URL    string          `json:"url"`
+	Job    string          `json:"job,omitempty"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// Client - talks to the dice meta service, safe for concurrent use
+type Client struct {
+	baseURL    string
+	tokens     TokenSource
+	httpClient *http.Client
+	userAgent  string
+
+	retry    transport.RetryPolicy
+	cmdRetry map[string]transport.RetryPolicy
+	safeCmds map[string]bool
+
+	dryRunMu sync.Mutex
+	dryRun   io.Writer
+
+	log io.Writer
+
+	refreshMu         sync.Mutex
+	refreshes         int
+	maxTokenRefreshes int
+}
+
+// NewClient - returns a Client for the given config
+func NewClient(cfg Config) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(cfg.BaseURL, "/"),
+		tokens:     cfg.Tokens,
+		httpClient: cfg.HTTPClient,
+		userAgent:  cfg.UserAgent,
+
+		maxTokenRefreshes: cfg.MaxTokenRefreshes,
+
+		retry:    cfg.Retry,
+		cmdRetry: cfg.CmdRetry,
+		safeCmds: cfg.SafeCmds,
+
+		dryRun: cfg.DryRun,
+		log:    cfg.Log,
+	}
+
+	if c.retry.MaxAttempts == 0 {
+		c.retry = transport.DefaultRetryPolicy
+	}
+	if c.log == nil {
+		c.log = os.Stdout
+	}
+	if c.safeCmds == nil {
+		c.safeCmds = RetrySafeCmds
+	}
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{Transport: transport.Retrying(nil, c.retry, c.log)}
+	}
+	if c.userAgent == "" {
+		c.userAgent = DefaultUserAgent
+	}
+	if c.maxTokenRefreshes == 0 {
+		c.maxTokenRefreshes = DefaultMaxTokenRefreshes
+	}
+
+	return c
+}
+
+// DryRun - reports whether requests other than GETs are only written, not sent
+func (c *Client) DryRun() bool {
+	return c.dryRun != nil
+}
+
+// BaseURL - returns the meta service url the client talks to
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// request - a single call to the meta service
+type request struct {
+	method string
+	path   string
+	body   string
+	header http.Header
+	// cmd - dice command of the call, selects the retry policy
+	cmd string
+	// id - job the call is made for, nil for non job calls
+	id *DataSourceID
+}
+
+// do - sends the request to the meta service and returns the response body
+//
+// Every non-2xx response is returned as an *APIError. On 401 or 403 the token is
+// refreshed once and the request replayed when the token source supports it.
+func (c *Client) do(ctx context.Context, req request) ([]byte, error) {
+	if c.dryRun != nil && req.method != http.MethodGet {
+		return nil, c.plan(req)
+	}
+
+	ctx = c.withRetry(ctx, req)
+
+	token, err := c.tokens.Token()
+	if err != nil {
+		return nil, fmt.Errorf("token: %v", err)
+	}
+
+	body, err := c.send(ctx, req, token)
+	if !IsUnauthorized(err) {
+		return body, err
+	}
+
+	newToken, refreshErr := c.refreshToken(token)
+	if refreshErr != nil {
+		return nil, errors.Join(err, refreshErr)
+	}
+
+	return c.send(ctx, req, newToken)
+}
+
+// plan - writes the request to the dry run writer
+func (c *Client) plan(req request) error {
+	planned := PlannedRequest{
+		Method: req.method,
+		URL:    c.baseURL + req.path,
+	}
+	if req.id != nil {
+		planned.Job = req.id.String()
+	}
+	if req.body != "" {
+		if !json.Valid([]byte(req.body)) {
+			return fmt.Errorf("dry run: body of %s %s is not valid json", req.method, planned.URL)
+		}
+		planned.Body = json.RawMessage(req.body)
+	}
+
+	data, err := json.Marshal(planned)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %v", err)
+	}
+
+	c.dryRunMu.Lock()
+	defer c.dryRunMu.Unlock()
+
+	if _, err := c.dryRun.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("dry run: %v", err)
+	}
+	return nil
+}
+
+// withRetry - attaches the retry policy of the request command to ctx
+func (c *Client) withRetry(ctx context.Context, req request) context.Context {
+	policy, ok := c.cmdRetry[req.cmd]
+	if !ok {
+		policy = c.retry
+	}
+	return transport.WithRetry(ctx, policy, req.method == http.MethodPost && c.safeCmds[req.cmd])
+}
+
+// refreshToken - returns a fresh token, unless another request already replaced the stale one
+func (c *Client) refreshToken(stale string) (string, error) {
+	refresher, ok := c.tokens.(RefreshableTokenSource)
+	if !ok {
+		return "", errors.New("token source can not be refreshed")
+	}
+
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	current, err := c.tokens.Token()
+	if err == nil && current != stale {
+		return current, nil
+	}
+
+	if c.maxTokenRefreshes < 0 || c.refreshes >= c.maxTokenRefreshes {
+		return "", ErrTokenRefreshLimit
+	}
+	c.refreshes++
+
+	fmt.Fprintf(c.log, "Refreshing token...(%d/%d)\n", c.refreshes, c.maxTokenRefreshes)
+	token, err := refresher.Refresh()
+	if err != nil {
+		return "", fmt.Errorf("token refresh: %v", err)
+	}
+
+	return token, nil
+}
+
+// send - sends the request once with the given token
+func (c *Client) send(ctx context.Context, req request, token string) ([]byte, error) {
+	var reqBody io.Reader
+	if req.body != "" {
+		reqBody = strings.NewReader(req.body)
+	}
+
+	url := c.baseURL + req.path
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, url, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("http.NewRequest: %v", err)
+	}
+
+	httpReq.Header = http.Header{
+		"Authorization": {"Bearer " + token},
+		"User-Agent":    {c.userAgent},
+	}
+	if reqBody != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	for key, values := range req.header {
+		httpReq.Header[key] = values
+	}
+
+	response, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("client.Do: %v", err)
+	}
+	defer response.Body.Close()
+
+	resBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body. %v", err)
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		jobID := ""
+		if req.id != nil {
+			jobID = req.id.String()
+		}
+		return nil, newAPIError(response.StatusCode, req.method, url, jobID, resBody)
+	}
+
+	return resBody, nil
+}
@@ -0,0 +1,81 @@
+package dice
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrInvalidDataSourceID - returned (wrapped) for malformed dataSourceIds
+var ErrInvalidDataSourceID = errors.New("invalid dataSourceId")
+
+// DataSourceID - identifies a dice job as <source>.<technology>.<database>.<schema>.<table>
+//
+// The table is everything after the fourth '.', so tables that contain dots are supported.
+type DataSourceID struct {
+	Source     string
+	Technology string
+	Database   string
+	Schema     string
+	Table      string
+}
+
+// ParseDataSourceID - parses a dataSourceId string
+func ParseDataSourceID(s string) (DataSourceID, error) {
+	parts := strings.SplitN(strings.TrimSpace(s), ".", 5)
+	if len(parts) != 5 {
+		return DataSourceID{}, fmt.Errorf(
+			"%w %q: expected <source>.<technology>.<database>.<schema>.<table>, got %d part(s)",
+			ErrInvalidDataSourceID, s, len(parts),
+		)
+	}
+
+	id := DataSourceID{
+		Source:     parts[0],
+		Technology: parts[1],
+		Database:   parts[2],
+		Schema:     parts[3],
+		Table:      parts[4],
+	}
+
+	names := []string{"source", "technology", "database", "schema", "table"}
+	for i, part := range parts {
+		if part == "" {
+			return DataSourceID{}, fmt.Errorf("%w %q: %s is empty", ErrInvalidDataSourceID, s, names[i])
+		}
+		if strings.ContainsAny(part, "/ \t") {
+			return DataSourceID{}, fmt.Errorf("%w %q: %s %q contains '/' or whitespace", ErrInvalidDataSourceID, s, names[i], part)
+		}
+	}
+
+	return id, nil
+}
+
+// String - returns the dotted dataSourceId
+func (id DataSourceID) String() string {
+	return strings.Join([]string{id.Source, id.Technology, id.Database, id.Schema, id.Table}, ".")
+}
+
+// JobName - returns the job name used by the meta service, <schema>.<table>
+func (id DataSourceID) JobName() string {
+	return id.Schema + "." + id.Table
+}
+
+// MetaPath - returns the url escaped meta service path of the job
+func (id DataSourceID) MetaPath() string {
+	return fmt.Sprintf(
+		"/sources/%s/technologies/%s/databases/%s/jobs/%s",
+		url.PathEscape(id.Source),
+		url.PathEscape(id.Technology),
+		url.PathEscape(id.Database),
+		url.PathEscape(id.JobName()),
+	)
+}
+
+// StoragePrefix - returns the dice fs folder of the job, <source>/<technology>/<database>/<schema>/<table>/
+//
+// Every '.' of the dataSourceId becomes a '/', also the ones inside the table, as dice fs lays the folders out that way.
+func (id DataSourceID) StoragePrefix() string {
+	return strings.ReplaceAll(id.String(), ".", "/") + "/"
+}
@@ -0,0 +1,67 @@
+package dice
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseDataSourceID(t *testing.T) {
+	tests := []struct {
+		in   string
+		want DataSourceID
+	}{
+		{"src.bq.db.schema.table", DataSourceID{"src", "bq", "db", "schema", "table"}},
+		{" src.bq.db.schema.table\n", DataSourceID{"src", "bq", "db", "schema", "table"}},
+		{"src.bq.db.schema.table.v2.part", DataSourceID{"src", "bq", "db", "schema", "table.v2.part"}},
+		{"bigquery-source.bigquery.prod_2434.ds.t-1", DataSourceID{"bigquery-source", "bigquery", "prod_2434", "ds", "t-1"}},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDataSourceID(tt.in)
+		if err != nil {
+			t.Errorf("ParseDataSourceID(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseDataSourceID(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseDataSourceIDInvalid(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"src",
+		"src.bq.db.schema",
+		".bq.db.schema.table",
+		"src..db.schema.table",
+		"src.bq.db.schema.",
+		"src.bq.db/x.schema.table",
+		"src.bq.db.sch ema.table",
+		"src.bq.db.schema.tab\tle",
+	} {
+		if _, err := ParseDataSourceID(in); !errors.Is(err, ErrInvalidDataSourceID) {
+			t.Errorf("ParseDataSourceID(%q) = %v, want ErrInvalidDataSourceID", in, err)
+		}
+	}
+}
+
+func TestDataSourceIDPaths(t *testing.T) {
+	id, err := ParseDataSourceID("src.bq.db.schema.table.v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := id.String(); got != "src.bq.db.schema.table.v2" {
+		t.Errorf("String() = %q", got)
+	}
+	if got := id.JobName(); got != "schema.table.v2" {
+		t.Errorf("JobName() = %q", got)
+	}
+	if got, want := id.MetaPath(), "/sources/src/technologies/bq/databases/db/jobs/schema.table.v2"; got != want {
+		t.Errorf("MetaPath() = %q, want %q", got, want)
+	}
+	if got, want := id.StoragePrefix(), "src/bq/db/schema/table/v2/"; got != want {
+		t.Errorf("StoragePrefix() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,97 @@
+package dice
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIError - returned for every non-2xx response of the dice meta service
+type APIError struct {
+	StatusCode int
+	Method     string
+	URL        string
+	// JobID - dataSourceId the request was made for, empty for non job calls
+	JobID string
+	// Message - error message decoded from the response body when it is json
+	Message string
+	// Body - raw response body
+	Body []byte
+}
+
+// Error - implements error
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.JobID != "" {
+		msg = fmt.Sprintf("job %s: %s", e.JobID, msg)
+	}
+
+	switch {
+	case e.Message != "":
+		msg += ": " + e.Message
+	case len(e.Body) > 0:
+		msg += ": " + strings.TrimSpace(string(e.Body))
+	}
+
+	return msg
+}
+
+// newAPIError - builds an APIError decoding the common dice and GCP error body shapes
+func newAPIError(statusCode int, method, url, jobID string, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: statusCode,
+		Method:     method,
+		URL:        url,
+		JobID:      jobID,
+		Body:       body,
+	}
+
+	decoded := struct {
+		Message string          `json:"message"`
+		Detail  string          `json:"detail"`
+		Error   json.RawMessage `json:"error"`
+	}{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return apiErr
+	}
+
+	nested := struct {
+		Message string `json:"message"`
+	}{}
+	errString := ""
+
+	switch {
+	case decoded.Message != "":
+		apiErr.Message = decoded.Message
+	case decoded.Detail != "":
+		apiErr.Message = decoded.Detail
+	case json.Unmarshal(decoded.Error, &errString) == nil && errString != "":
+		apiErr.Message = errString
+	case json.Unmarshal(decoded.Error, &nested) == nil && nested.Message != "":
+		apiErr.Message = nested.Message
+	}
+
+	return apiErr
+}
+
+// IsStatus - reports whether err is an APIError with one of the given status codes
+func IsStatus(err error, statusCodes ...int) bool {
+	apiErr := &APIError{}
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	for _, code := range statusCodes {
+		if apiErr.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUnauthorized - reports whether err is a 401 or 403 APIError
+func IsUnauthorized(err error) bool {
+	return IsStatus(err, http.StatusUnauthorized, http.StatusForbidden)
+}
@@ -0,0 +1,101 @@
+package dice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ListJobs - returns the jobs of a database of the meta service
+//
+// The response may be an array, or an object holding it under jobs, items or data, of
+// dataSourceIds, job names (<schema>.<table>) or job objects carrying either.
+func (c *Client) ListJobs(ctx context.Context, source, technology, database string) ([]DataSourceID, error) {
+	body, err := c.do(ctx, request{
+		method: http.MethodGet,
+		path: fmt.Sprintf(
+			"/sources/%s/technologies/%s/databases/%s/jobs",
+			url.PathEscape(source), url.PathEscape(technology), url.PathEscape(database),
+		),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := listItems(body)
+	if err != nil {
+		return nil, fmt.Errorf("decode jobs of %s.%s.%s: %v", source, technology, database, err)
+	}
+
+	prefix := strings.Join([]string{source, technology, database}, ".") + "."
+	ids := []DataSourceID{}
+	for _, item := range items {
+		name, err := jobItemName(item)
+		if err != nil {
+			return nil, fmt.Errorf("decode jobs of %s.%s.%s: %v", source, technology, database, err)
+		}
+
+		if !strings.HasPrefix(name, prefix) {
+			name = prefix + name
+		}
+		id, err := ParseDataSourceID(name)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// listItems - returns the elements of a list response
+func listItems(body []byte) ([]json.RawMessage, error) {
+	items := []json.RawMessage{}
+	if err := json.Unmarshal(body, &items); err == nil {
+		return items, nil
+	}
+
+	wrapper := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &wrapper); err != nil {
+		return nil, err
+	}
+	for _, key := range []string{"jobs", "items", "data"} {
+		if raw, ok := wrapper[key]; ok {
+			if err := json.Unmarshal(raw, &items); err != nil {
+				return nil, fmt.Errorf("%s: %v", key, err)
+			}
+			return items, nil
+		}
+	}
+
+	return nil, fmt.Errorf("expected a list of jobs")
+}
+
+// jobItemName - returns the dataSourceId or job name of a list element
+func jobItemName(item json.RawMessage) (string, error) {
+	var name string
+	if err := json.Unmarshal(item, &name); err == nil {
+		return name, nil
+	}
+
+	fields := map[string]any{}
+	if err := json.Unmarshal(item, &fields); err != nil {
+		return "", fmt.Errorf("unexpected job %s", item)
+	}
+
+	for _, key := range []string{"dataSourceId", "name", "jobName"} {
+		if name, ok := fields[key].(string); ok && name != "" {
+			return name, nil
+		}
+	}
+	schema, _ := fields["schema"].(string)
+	table, _ := fields["table"].(string)
+	if schema != "" && table != "" {
+		return schema + "." + table, nil
+	}
+
+	return "", fmt.Errorf("job %s has no dataSourceId or name", item)
+}
@@ -0,0 +1,83 @@
+package dice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// stateFields, lockFields - job definition fields the state and lock status are read from,
+// the first one present wins
+//
+// These names are an assumption, the meta service contract does not document where a job
+// definition keeps its state and lock. state and locked are the fields of the definitions
+// in testdata/job_definition.json, the other names are fallbacks so a renamed field still
+// shows up instead of an empty column.
+var (
+	stateFields = []string{"state", "status", "jobState", "jobStatus"}
+	lockFields  = []string{"locked", "isLocked", "lock", "lockStatus"}
+)
+
+// JobStatus - the key fields of a job definition
+type JobStatus struct {
+	Job              string   `json:"job"`
+	State            string   `json:"state"`
+	Locked           bool     `json:"locked"`
+	Schedule         string   `json:"schedule"`
+	CronTimezone     string   `json:"cronTimezone"`
+	NewLakeJob       bool     `json:"newLakeJob"`
+	TargetProjectIds []string `json:"targetProjectIds"`
+}
+
+// GetJobStatus - returns the key fields of the job definition
+func (c *Client) GetJobStatus(ctx context.Context, id DataSourceID) (JobStatus, error) {
+	body, err := c.GetJob(ctx, id)
+	if err != nil {
+		return JobStatus{}, err
+	}
+
+	status, err := ParseJobStatus(body)
+	if err != nil {
+		return JobStatus{}, err
+	}
+	status.Job = id.String()
+	return status, nil
+}
+
+// ParseJobStatus - extracts the key fields of a job definition
+//
+// Fields of an unexpected type are left empty rather than failing the whole job.
+func ParseJobStatus(body []byte) (JobStatus, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return JobStatus{}, fmt.Errorf("decode job: %v", err)
+	}
+
+	status := JobStatus{TargetProjectIds: []string{}}
+	json.Unmarshal(fields["schedule"], &status.Schedule)
+	json.Unmarshal(fields["cronTimezone"], &status.CronTimezone)
+	json.Unmarshal(fields["newLakeJob"], &status.NewLakeJob)
+	json.Unmarshal(fields["targetProjectIds"], &status.TargetProjectIds)
+
+	for _, name := range stateFields {
+		if raw, ok := fields[name]; ok && json.Unmarshal(raw, &status.State) == nil {
+			break
+		}
+	}
+
+	for _, name := range lockFields {
+		raw, ok := fields[name]
+		if !ok {
+			continue
+		}
+
+		var lockStatus string
+		if json.Unmarshal(raw, &status.Locked) != nil && json.Unmarshal(raw, &lockStatus) == nil {
+			status.Locked = strings.EqualFold(lockStatus, "locked") || strings.EqualFold(lockStatus, "true")
+		}
+		break
+	}
+
+	return status, nil
+}
@@ -0,0 +1,88 @@
+package dice
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+)
+
+type staticTokens string
+
+func (t staticTokens) Token() (string, error) { return string(t), nil }
+
+func TestGetJobStatus(t *testing.T) {
+	definition, err := os.ReadFile("testdata/job_definition.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := ParseDataSourceID("sap.bq.db.sales.orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != id.MetaPath() {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(definition)
+	}))
+	defer server.Close()
+
+	client := NewClient(Config{BaseURL: server.URL, Tokens: staticTokens("token")})
+	status, err := client.GetJobStatus(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := JobStatus{
+		Job:              "sap.bq.db.sales.orders",
+		State:            "PAUSED",
+		Locked:           true,
+		Schedule:         "30 4 * * *",
+		CronTimezone:     "America/Chicago",
+		NewLakeJob:       true,
+		TargetProjectIds: []string{"prep-2134-entdatalake-969cbf", "prep-2134-lake-1a2b3c"},
+	}
+	if !reflect.DeepEqual(status, want) {
+		t.Errorf("GetJobStatus = %+v, want %+v", status, want)
+	}
+}
+
+func TestParseJobStatusFallbackFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		state  string
+		locked bool
+	}{
+		{"first field wins", `{"state": "ACTIVE", "status": "PAUSED", "locked": false, "isLocked": true}`, "ACTIVE", false},
+		{"fallback names", `{"jobState": "STOPPED", "isLocked": true}`, "STOPPED", true},
+		{"lock status text", `{"jobStatus": "RUNNING", "lockStatus": "LOCKED"}`, "RUNNING", true},
+		{"lock status unlocked", `{"lock": "unlocked"}`, "", false},
+		{"unexpected types left empty", `{"state": 3, "status": "PAUSED", "locked": 1}`, "PAUSED", false},
+		{"no state or lock", `{"schedule": "0 1 * * *"}`, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, err := ParseJobStatus([]byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.State != tt.state || status.Locked != tt.locked {
+				t.Errorf("ParseJobStatus = state %q locked %v, want %q %v", status.State, status.Locked, tt.state, tt.locked)
+			}
+			if status.TargetProjectIds == nil {
+				t.Error("TargetProjectIds is nil, want an empty list")
+			}
+		})
+	}
+
+	if _, err := ParseJobStatus([]byte(`[]`)); err == nil {
+		t.Error("ParseJobStatus of a json array succeeded")
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mah35h95/break-time/jsonpatch"
+	"github.com/tidwall/sjson"
+)
+
+// buildEdit - returns the editFunc of the edit command
+//
+// The merge patch is applied first, then the JSON Patch, then the --set and --delete
+// paths in the order they were given.
+func buildEdit(sets, deletes []string, patchFile, mergePatchFile string) (editFunc, error) {
+	if len(sets) == 0 && len(deletes) == 0 && patchFile == "" && mergePatchFile == "" {
+		return nil, errors.New("nothing to edit, give --set, --delete, --patch-file or --merge-patch-file")
+	}
+
+	type assignment struct {
+		path  string
+		value string
+	}
+	assignments := []assignment{}
+	for _, set := range sets {
+		path, value, ok := strings.Cut(set, "=")
+		if !ok || path == "" {
+			return nil, fmt.Errorf("--set %q: expected <path>=<value>", set)
+		}
+		assignments = append(assignments, assignment{path: path, value: value})
+	}
+
+	var mergePatch []byte
+	if mergePatchFile != "" {
+		data, err := os.ReadFile(mergePatchFile)
+		if err != nil {
+			return nil, fmt.Errorf("--merge-patch-file: %v", err)
+		}
+		if !json.Valid(data) {
+			return nil, fmt.Errorf("--merge-patch-file %s is not valid json", mergePatchFile)
+		}
+		mergePatch = data
+	}
+
+	var patch jsonpatch.Patch
+	if patchFile != "" {
+		data, err := os.ReadFile(patchFile)
+		if err != nil {
+			return nil, fmt.Errorf("--patch-file: %v", err)
+		}
+		patch, err = jsonpatch.ParsePatch(data)
+		if err != nil {
+			return nil, fmt.Errorf("--patch-file %s: %v", patchFile, err)
+		}
+	}
+
+	return func(job []byte) (string, error) {
+		var err error
+		if mergePatch != nil {
+			if job, err = jsonpatch.MergePatch(job, mergePatch); err != nil {
+				return "", err
+			}
+		}
+		if patch != nil {
+			if job, err = patch.Apply(job); err != nil {
+				return "", err
+			}
+		}
+
+		edited := string(job)
+		for _, a := range assignments {
+			if json.Valid([]byte(a.value)) {
+				edited, err = sjson.SetRaw(edited, a.path, a.value)
+			} else {
+				edited, err = sjson.Set(edited, a.path, a.value)
+			}
+			if err != nil {
+				return "", fmt.Errorf("--set %s: %v", a.path, err)
+			}
+		}
+		for _, path := range deletes {
+			if edited, err = sjson.Delete(edited, path); err != nil {
+				return "", fmt.Errorf("--delete %s: %v", path, err)
+			}
+		}
+
+		return edited, nil
+	}, nil
+}
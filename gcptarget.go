@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Target modes of edit-gcp-target
+const (
+	targetModeAdd     = "add"
+	targetModeRemove  = "remove"
+	targetModeReplace = "replace"
+)
+
+// projectIDPattern - GCP project ids are 6 to 30 lowercase letters, digits or hyphens,
+// starting with a letter and not ending with a hyphen
+var projectIDPattern = regexp.MustCompile(`^[a-z][a-z0-9-]{4,28}[a-z0-9]$`)
+
+// gcpTargets - target fields of a job definition
+type gcpTargets struct {
+	TargetProjectIds []string          `json:"targetProjectIds"`
+	JdbcTargets      []json.RawMessage `json:"jdbcTargets"`
+}
+
+// gcpTargetConfig - --config file of edit-gcp-target, nil fields are left as they are
+type gcpTargetConfig struct {
+	Mode             string             `json:"mode"`
+	TargetProjectIds *[]string          `json:"targetProjectIds"`
+	JdbcTargets      *[]json.RawMessage `json:"jdbcTargets"`
+}
+
+// buildGCPTargetEdit - returns the editFunc of edit-gcp-target
+//
+// Projects and jdbc targets of the flags are added to the ones of the config file.
+// Only the fields that were given are posted, so replacing the projects keeps the jdbc targets.
+func buildGCPTargetEdit(mode string, projects, jdbcTargets []string, configFile string) (editFunc, error) {
+	config := gcpTargetConfig{}
+	if configFile != "" {
+		data, err := os.ReadFile(configFile)
+		if err != nil {
+			return nil, fmt.Errorf("--config: %v", err)
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("--config %s: %v", configFile, err)
+		}
+	}
+
+	if mode == "" {
+		mode = config.Mode
+	}
+	if mode == "" {
+		mode = targetModeReplace
+	}
+	if !slices.Contains([]string{targetModeAdd, targetModeRemove, targetModeReplace}, mode) {
+		return nil, fmt.Errorf("--mode must be one of add, remove, replace, got %q", mode)
+	}
+
+	for _, list := range projects {
+		for _, project := range strings.Split(list, ",") {
+			if project = strings.TrimSpace(project); project != "" {
+				if config.TargetProjectIds == nil {
+					config.TargetProjectIds = &[]string{}
+				}
+				*config.TargetProjectIds = append(*config.TargetProjectIds, project)
+			}
+		}
+	}
+	for _, target := range jdbcTargets {
+		if config.JdbcTargets == nil {
+			config.JdbcTargets = &[]json.RawMessage{}
+		}
+		*config.JdbcTargets = append(*config.JdbcTargets, json.RawMessage(target))
+	}
+
+	if config.TargetProjectIds == nil && config.JdbcTargets == nil {
+		return nil, errors.New("no targets given, use --target-project, --jdbc-target or --config")
+	}
+
+	errs := []error{}
+	if config.TargetProjectIds != nil {
+		for _, project := range *config.TargetProjectIds {
+			if !projectIDPattern.MatchString(project) {
+				errs = append(errs, fmt.Errorf("invalid GCP project id %q", project))
+			}
+		}
+	}
+	if config.JdbcTargets != nil {
+		for i, target := range *config.JdbcTargets {
+			compacted, err := compactJSON(target)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("jdbc target %s is not valid json", target))
+				continue
+			}
+			(*config.JdbcTargets)[i] = compacted
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return func(job []byte) (string, error) {
+		current := gcpTargets{}
+		if err := json.Unmarshal(job, &current); err != nil {
+			return "", fmt.Errorf("read current targets: %v", err)
+		}
+
+		patch := map[string]any{}
+		if config.TargetProjectIds != nil {
+			patch["targetProjectIds"] = applyTargetMode(mode, current.TargetProjectIds, *config.TargetProjectIds, func(a, b string) bool {
+				return a == b
+			})
+		}
+		if config.JdbcTargets != nil {
+			for i, target := range current.JdbcTargets {
+				if compacted, err := compactJSON(target); err == nil {
+					current.JdbcTargets[i] = compacted
+				}
+			}
+			patch["jdbcTargets"] = applyTargetMode(mode, current.JdbcTargets, *config.JdbcTargets, func(a, b json.RawMessage) bool {
+				return bytes.Equal(a, b)
+			})
+		}
+
+		body, err := json.Marshal(patch)
+		if err != nil {
+			return "", fmt.Errorf("json.Marshal: %v", err)
+		}
+		return string(body), nil
+	}, nil
+}
+
+// applyTargetMode - returns the targets of the job after adding, removing or replacing the given ones
+func applyTargetMode[T any](mode string, current, given []T, equal func(a, b T) bool) []T {
+	contains := func(list []T, item T) bool {
+		return slices.ContainsFunc(list, func(other T) bool { return equal(other, item) })
+	}
+
+	result := []T{}
+	switch mode {
+	case targetModeAdd:
+		result = append(result, current...)
+		for _, target := range given {
+			if !contains(result, target) {
+				result = append(result, target)
+			}
+		}
+	case targetModeRemove:
+		for _, target := range current {
+			if !contains(given, target) {
+				result = append(result, target)
+			}
+		}
+	default:
+		for _, target := range given {
+			if !contains(result, target) {
+				result = append(result, target)
+			}
+		}
+	}
+
+	return result
+}
+
+// compactJSON - returns the json without insignificant whitespace, so targets compare equal
+func compactJSON(data []byte) (json.RawMessage, error) {
+	buf := bytes.Buffer{}
+	if err := json.Compact(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestApplyTargetMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		current []string
+		given   []string
+		want    []string
+	}{
+		{"add appends the missing ones", targetModeAdd, []string{"prep-1", "qa-2"}, []string{"qa-2", "dev-3"}, []string{"prep-1", "qa-2", "dev-3"}},
+		{"add to none", targetModeAdd, nil, []string{"dev-3", "dev-3"}, []string{"dev-3"}},
+		{"add keeps duplicates of the job", targetModeAdd, []string{"qa-2", "qa-2"}, []string{"qa-2"}, []string{"qa-2", "qa-2"}},
+		{"remove", targetModeRemove, []string{"prep-1", "qa-2", "dev-3"}, []string{"qa-2", "missing-4"}, []string{"prep-1", "dev-3"}},
+		{"remove every one", targetModeRemove, []string{"qa-2", "qa-2"}, []string{"qa-2"}, []string{}},
+		{"replace", targetModeReplace, []string{"prep-1"}, []string{"qa-2", "dev-3", "qa-2"}, []string{"qa-2", "dev-3"}},
+		{"replace with none", targetModeReplace, []string{"prep-1"}, []string{}, []string{}},
+	}
+
+	for _, tt := range tests {
+		got := applyTargetMode(tt.mode, tt.current, tt.given, func(a, b string) bool { return a == b })
+		if !slices.Equal(got, tt.want) || got == nil {
+			t.Errorf("%s: applyTargetMode = %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGCPTargetEdit(t *testing.T) {
+	job := `{"targetProjectIds":["prep-1234","qa-1234-lake"],"jdbcTargets":[{"url": "jdbc:a","user":"u"}],"other":1}`
+
+	tests := []struct {
+		name     string
+		mode     string
+		projects []string
+		jdbc     []string
+		want     string
+	}{
+		{
+			name:     "replace projects only keeps the jdbc targets",
+			mode:     targetModeReplace,
+			projects: []string{"dev-1234, qa-9999"},
+			want:     `{"targetProjectIds":["dev-1234","qa-9999"]}`,
+		},
+		{
+			name:     "add projects from repeated flags",
+			mode:     targetModeAdd,
+			projects: []string{"qa-1234-lake", "dev-1234"},
+			want:     `{"targetProjectIds":["prep-1234","qa-1234-lake","dev-1234"]}`,
+		},
+		{
+			name: "remove a jdbc target written with other whitespace",
+			mode: targetModeRemove,
+			jdbc: []string{`{"url":"jdbc:a", "user":"u"}`},
+			want: `{"jdbcTargets":[]}`,
+		},
+		{
+			name:     "default mode is replace",
+			projects: []string{"dev-1234"},
+			jdbc:     []string{`{"url":"jdbc:b"}`},
+			want:     `{"jdbcTargets":[{"url":"jdbc:b"}],"targetProjectIds":["dev-1234"]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		edit, err := buildGCPTargetEdit(tt.mode, tt.projects, tt.jdbc, "")
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		got, err := edit([]byte(job))
+		if err != nil {
+			t.Errorf("%s: edit: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: edit = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGCPTargetEditConfig(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "targets.json")
+	os.WriteFile(config, []byte(`{"mode":"add","targetProjectIds":["dev-1234"]}`), 0644)
+
+	// the flags add to the config, the --mode flag wins over the mode of the config
+	edit, err := buildGCPTargetEdit("", []string{"qa-9999"}, nil, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := edit([]byte(`{"targetProjectIds":["prep-1234"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"targetProjectIds":["prep-1234","dev-1234","qa-9999"]}`; got != want {
+		t.Errorf("edit = %s, want %s", got, want)
+	}
+
+	edit, err = buildGCPTargetEdit(targetModeReplace, nil, nil, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := edit([]byte(`{"targetProjectIds":["prep-1234"]}`)); got != `{"targetProjectIds":["dev-1234"]}` {
+		t.Errorf("edit with --mode replace = %s", got)
+	}
+}
+
+func TestGCPTargetEditInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		projects []string
+		jdbc     []string
+	}{
+		{"unknown mode", "merge", []string{"dev-1234"}, nil},
+		{"no targets", targetModeAdd, []string{" , "}, nil},
+		{"uppercase project", targetModeAdd, []string{"Dev-1234"}, nil},
+		{"project too short", targetModeAdd, []string{"dev1"}, nil},
+		{"project too long", targetModeAdd, []string{"a23456789012345678901234567890x"}, nil},
+		{"project starting with a digit", targetModeAdd, []string{"1dev-1234"}, nil},
+		{"project ending with a hyphen", targetModeAdd, []string{"dev-1234-"}, nil},
+		{"project with an underscore", targetModeAdd, []string{"dev_1234"}, nil},
+		{"jdbc target not json", targetModeAdd, nil, []string{`{"url":`}},
+	}
+
+	for _, tt := range tests {
+		if _, err := buildGCPTargetEdit(tt.mode, tt.projects, tt.jdbc, ""); err == nil {
+			t.Errorf("%s: buildGCPTargetEdit succeeded, want an error", tt.name)
+		}
+	}
+
+	for _, valid := range []string{"abcdef", "qa-1234-lake", "a23456789012345678901234567890"} {
+		if !projectIDPattern.MatchString(valid) {
+			t.Errorf("project id %q rejected", valid)
+		}
+	}
+}
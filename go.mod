@@ -2,7 +2,10 @@ module github.com/mah35h95/break-time
 
 go 1.22
 
-require github.com/tidwall/sjson v1.2.5
+require (
+	github.com/tidwall/sjson v1.2.5
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/tidwall/gjson v1.17.3 // indirect
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/mah35h95/break-time/dice"
+)
+
+// environment - the environment a GCP project belongs to
+type environment string
+
+const (
+	envDev     environment = "dev"
+	envQA      environment = "qa"
+	envPrep    environment = "prep"
+	envProd    environment = "prod"
+	envUnknown environment = "unknown"
+)
+
+// DestructiveCmds - dice commands that need confirmation before running against prod
+var DestructiveCmds = map[string]bool{
+	dice.Delete:            true,
+	dice.DeleteHydratedRes: true,
+	dice.CleanFS:           true,
+	dice.Stop:              true,
+	dice.Reload:            true,
+	// playbookCmd - steps may delete or stop the jobs
+	playbookCmd: true,
+}
+
+// classifyProject - returns the environment of the project from its name, eg. prod-2367-... => prod
+func classifyProject(project string) environment {
+	prefix, _, _ := strings.Cut(strings.ToLower(project), "-")
+	switch prefix {
+	case "dev":
+		return envDev
+	case "qa":
+		return envQA
+	case "prep", "preprod":
+		return envPrep
+	case "prod", "prd":
+		return envProd
+	}
+	return envUnknown
+}
+
+// confirmProd - makes sure destructive commands against a prod project are meant
+//
+// The project name has to be typed on the terminal unless --yes-i-mean-prod is given.
+// Dry runs send nothing, so they are never asked. The question is written to progress.
+func confirmProd(cmd *command, opts options, progress io.Writer) error {
+	if !DestructiveCmds[cmd.cmd] || classifyProject(opts.Project) != envProd || opts.DryRun {
+		return nil
+	}
+
+	if opts.YesIMeanProd {
+		fmt.Fprintf(progress, "Running %s against prod project %s (--yes-i-mean-prod)\n", cmd.name, opts.Project)
+		return nil
+	}
+
+	in, err := confirmInput(opts)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	fmt.Fprintf(progress, "%s is a prod project and %s can not be undone.\n", opts.Project, cmd.name)
+	fmt.Fprint(progress, "Type the project name to continue: ")
+
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("read confirmation: %v", err)
+	}
+	if strings.TrimSpace(answer) != opts.Project {
+		return errors.New("confirmation did not match the project name")
+	}
+
+	return nil
+}
+
+// confirmInput - returns the terminal to read the confirmation from
+//
+// Stdin is only used when it is a terminal not already read for the job list.
+func confirmInput(opts options) (io.ReadCloser, error) {
+	if tty, err := os.Open("/dev/tty"); err == nil {
+		return tty, nil
+	}
+
+	stat, err := os.Stdin.Stat()
+	if err == nil && stat.Mode()&os.ModeCharDevice != 0 && !slices.Contains(opts.JobsFiles, "-") {
+		return io.NopCloser(os.Stdin), nil
+	}
+
+	return nil, errors.New("no terminal to confirm running against prod, pass --yes-i-mean-prod to run without confirmation")
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mah35h95/break-time/dice"
+	"github.com/mah35h95/break-time/utils"
+)
+
+// jobListExts - files picked up when a directory is given as --jobs-file
+var jobListExts = []string{".txt", ".csv", ".json", ".jobs"}
+
+// JobIDs - returns the parsed, de-duplicated dataSourceIds of --jobs and --jobs-file,
+// or the default jobs of the command when neither is given
+//
+// Every id is validated before returning, so a typo aborts the run before any api call.
+// Skipped duplicates are reported to progress.
+func (o options) JobIDs(progress io.Writer) ([]dice.DataSourceID, error) {
+	entries := []utils.JobEntry{}
+
+	for i, id := range strings.Split(o.Jobs, "/") {
+		if id = strings.TrimSpace(id); id != "" {
+			entries = append(entries, utils.JobEntry{ID: id, Source: fmt.Sprintf("--jobs[%d]", i)})
+		}
+	}
+
+	for _, pattern := range o.JobsFiles {
+		read, err := readJobLists(pattern)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, read...)
+	}
+
+	if len(entries) == 0 {
+		entries = o.defaultJobs
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("no jobs provided")
+	}
+
+	ids := make([]dice.DataSourceID, 0, len(entries))
+	seen := map[string]bool{}
+	duplicates := 0
+	errs := []error{}
+
+	for _, entry := range entries {
+		id, err := dice.ParseDataSourceID(entry.ID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", entry.Source, err))
+			continue
+		}
+
+		if seen[id.String()] {
+			duplicates++
+			continue
+		}
+		seen[id.String()] = true
+		ids = append(ids, id)
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if duplicates > 0 {
+		fmt.Fprintf(progress, "Skipping %d duplicate job(s)\n", duplicates)
+	}
+
+	return ids, nil
+}
+
+// readJobLists - reads stdin for '-', every job list file of a directory, or the files matching a glob
+func readJobLists(pattern string) ([]utils.JobEntry, error) {
+	if pattern == "-" {
+		return utils.ReadJobList("stdin", os.Stdin)
+	}
+
+	names, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("--jobs-file %s: %v", pattern, err)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("--jobs-file %s: no such file", pattern)
+	}
+
+	entries := []utils.JobEntry{}
+	for _, name := range names {
+		info, err := os.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+
+		files := []string{name}
+		if info.IsDir() {
+			if files, err = jobListFiles(name); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, file := range files {
+			read, err := readJobListFile(file)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, read...)
+		}
+	}
+
+	return entries, nil
+}
+
+// jobListFiles - returns the job list files directly inside dir
+func jobListFiles(dir string) ([]string, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read jobs dir: %v", err)
+	}
+
+	files := []string{}
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() || !slices.Contains(jobListExts, strings.ToLower(filepath.Ext(dirEntry.Name()))) {
+			continue
+		}
+		files = append(files, filepath.Join(dir, dirEntry.Name()))
+	}
+
+	return files, nil
+}
+
+// readJobListFile - reads a single job list file
+func readJobListFile(name string) ([]utils.JobEntry, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("read jobs file: %v", err)
+	}
+	defer file.Close()
+
+	return utils.ReadJobList(name, file)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mah35h95/break-time/auth"
+)
+
+func TestJobIDsMergesAndDeduplicates(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("src.bq.db.s.t1\nsrc.bq.db.s.t2\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "b.csv"), []byte("dataSourceId\nsrc.bq.db.s.t2\nsrc.bq.db.s.t3\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte("src.bq.db.s.t9\n"), 0644)
+
+	opts := options{Jobs: "src.bq.db.s.t3/ src.bq.db.s.t0 /", JobsFiles: []string{dir}}
+	log := &strings.Builder{}
+	ids, err := opts.JobIDs(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log.String() != "Skipping 2 duplicate job(s)\n" {
+		t.Errorf("JobIDs reported %q", log.String())
+	}
+
+	got := []string{}
+	for _, id := range ids {
+		got = append(got, id.String())
+	}
+	want := "src.bq.db.s.t3,src.bq.db.s.t0,src.bq.db.s.t1,src.bq.db.s.t2"
+	if strings.Join(got, ",") != want {
+		t.Errorf("JobIDs = %v, want %s", got, want)
+	}
+}
+
+func TestJobIDsReportsWhereInvalidIDsCameFrom(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "jobs.txt")
+	os.WriteFile(file, []byte("src.bq.db.s.t1\n# comment\nsrc.bq\n"), 0644)
+
+	_, err := options{JobsFiles: []string{file}}.JobIDs(io.Discard)
+	if err == nil || !strings.Contains(err.Error(), file+":3") {
+		t.Errorf("JobIDs error = %v, want one naming %s:3", err, file)
+	}
+
+	if _, err := (options{JobsFiles: []string{filepath.Join(t.TempDir(), "*.txt")}}).JobIDs(io.Discard); err == nil {
+		t.Error("JobIDs of a glob matching nothing succeeded")
+	}
+}
+
+func TestParseArgsJobsEnvFallback(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "jobs.txt")
+	os.WriteFile(file, []byte("src.bq.db.s.t1\n"), 0644)
+	t.Setenv("JOBS", "src.bq.db.s.env")
+	t.Setenv("AUTH_BACKEND", auth.BackendGcloud)
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"env fallback", []string{"pause", "--project", "qa-1"}, "src.bq.db.s.env"},
+		{"ignored along with --jobs-file", []string{"pause", "--project", "qa-1", "--jobs-file", file}, ""},
+		{"--jobs given along with --jobs-file", []string{"pause", "--project", "qa-1", "--jobs-file", file, "--jobs", "src.bq.db.s.t2"}, "src.bq.db.s.t2"},
+	}
+
+	for _, tt := range tests {
+		_, opts, _, err := parseArgs(tt.args)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if opts.Jobs != tt.want {
+			t.Errorf("%s: --jobs = %q, want %q", tt.name, opts.Jobs, tt.want)
+		}
+	}
+}
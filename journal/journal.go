@@ -0,0 +1,301 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	runFile     = "run.json"
+	journalFile = "journal.jsonl"
+)
+
+// Status - state of a job within a run
+type Status string
+
+const (
+	StatusStarted   Status = "started"
+	StatusCompleted Status = "completed"
+	StatusFailed    Status = "failed"
+	// StatusStepCompleted - a step of a multi step job completed, the job status stays as it was
+	StatusStepCompleted Status = "step-completed"
+)
+
+// Run - describes a run, written once to run.json when the run is created
+type Run struct {
+	ID      string `json:"id"`
+	Command string `json:"command"`
+	Project string `json:"project"`
+	// MetaURL - meta service url the run was sent to, empty for the one of the project
+	MetaURL string    `json:"metaUrl,omitempty"`
+	Args    []string  `json:"args"`
+	Jobs    []string  `json:"jobs"`
+	DryRun  bool      `json:"dryRun,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// Entry - a single line of journal.jsonl
+type Entry struct {
+	Time   time.Time `json:"time"`
+	Job    string    `json:"job"`
+	Status Status    `json:"status"`
+	// Step - number of the completed step, StatusStepCompleted only
+	Step  int    `json:"step,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Journal - append only record of the job statuses of a run, safe for concurrent use
+type Journal struct {
+	// Log - unreadable journal lines are reported to it, os.Stdout when nil
+	Log io.Writer
+
+	dir string
+	run Run
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewRunID - returns a sortable, timestamped run id for the command
+func NewRunID(command string, now time.Time) string {
+	return fmt.Sprintf("%s-%s", now.Format("20060102-150405"), command)
+}
+
+// Create - creates the directory of a new run under runsDir, run.ID is assigned when empty
+//
+// Assigned ids get a -2, -3... suffix when another run of the command started in the same second.
+func Create(runsDir string, run Run) (*Journal, error) {
+	if run.Created.IsZero() {
+		run.Created = time.Now()
+	}
+	if err := os.MkdirAll(runsDir, 0755); err != nil {
+		return nil, fmt.Errorf("create runs dir: %v", err)
+	}
+
+	assignID := run.ID == ""
+	base := run.ID
+	if assignID {
+		base = NewRunID(run.Command, run.Created)
+	}
+
+	run.ID = base
+	for n := 2; ; n++ {
+		err := os.Mkdir(filepath.Join(runsDir, run.ID), 0755)
+		if err == nil || (errors.Is(err, os.ErrExist) && !assignID) {
+			break
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("create run dir: %v", err)
+		}
+		run.ID = fmt.Sprintf("%s-%d", base, n)
+	}
+	dir := filepath.Join(runsDir, run.ID)
+
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal: %v", err)
+	}
+
+	runPath := filepath.Join(dir, runFile)
+	file, err := os.OpenFile(runPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("create %s: %v", runPath, err)
+	}
+	_, err = file.Write(append(data, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("write %s: %v", runPath, err)
+	}
+
+	return open(dir, run)
+}
+
+// ReadRun - reads run.json of an existing run
+func ReadRun(runsDir, id string) (Run, error) {
+	data, err := os.ReadFile(filepath.Join(runsDir, id, runFile))
+	if err != nil {
+		return Run{}, fmt.Errorf("read run %s: %v", id, err)
+	}
+
+	run := Run{}
+	if err := json.Unmarshal(data, &run); err != nil {
+		return Run{}, fmt.Errorf("decode run %s: %v", id, err)
+	}
+
+	return run, nil
+}
+
+// Open - opens the journal of an existing run to resume it
+func Open(runsDir, id string) (*Journal, error) {
+	run, err := ReadRun(runsDir, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return open(filepath.Join(runsDir, id), run)
+}
+
+// open - opens journal.jsonl of the run dir for appending
+//
+// A partly written last line left by a crash is ended first, so the next entry starts on a line of its own.
+func open(dir string, run Run) (*Journal, error) {
+	path := filepath.Join(dir, journalFile)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %v", path, err)
+	}
+
+	if err := endLastLine(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("open %s: %v", path, err)
+	}
+
+	return &Journal{dir: dir, run: run, file: file}, nil
+}
+
+// endLastLine - appends a newline when the file does not end with one
+func endLastLine(file *os.File) error {
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+		return err
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+	_, err = file.Write([]byte{'\n'})
+	return err
+}
+
+// Run - returns the run the journal belongs to
+func (j *Journal) Run() Run {
+	return j.run
+}
+
+// Dir - returns the directory of the run
+func (j *Journal) Dir() string {
+	return j.dir
+}
+
+// Record - appends the status of a job, err is recorded as the failure reason
+func (j *Journal) Record(job string, status Status, err error) error {
+	entry := Entry{Time: time.Now(), Job: job, Status: status}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	return j.write(entry)
+}
+
+// RecordStep - appends the step a multi step job completed, steps count from 1
+func (j *Journal) RecordStep(job string, step int) error {
+	return j.write(Entry{Time: time.Now(), Job: job, Status: StatusStepCompleted, Step: step})
+}
+
+// write - appends an entry to journal.jsonl
+func (j *Journal) write(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %v", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write journal: %v", err)
+	}
+	return nil
+}
+
+// Statuses - returns the latest recorded status of every job in the journal
+func (j *Journal) Statuses() (map[string]Status, error) {
+	statuses := map[string]Status{}
+	err := j.scan(func(entry Entry) {
+		if entry.Status != StatusStepCompleted {
+			statuses[entry.Job] = entry.Status
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// Steps - returns the last step every multi step job of the journal completed
+func (j *Journal) Steps() (map[string]int, error) {
+	steps := map[string]int{}
+	err := j.scan(func(entry Entry) {
+		if entry.Status == StatusStepCompleted {
+			steps[entry.Job] = entry.Step
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// scan - calls fn with every readable entry of journal.jsonl in order
+func (j *Journal) scan(fn func(entry Entry)) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	file, err := os.Open(filepath.Join(j.dir, journalFile))
+	if err != nil {
+		return fmt.Errorf("open journal: %v", err)
+	}
+	defer file.Close()
+
+	log := j.Log
+	if log == nil {
+		log = os.Stdout
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		entry := Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// a crash can leave a partly written last line behind
+			fmt.Fprintf(log, "Skipping unreadable journal line %d: %v\n", line, err)
+			continue
+		}
+		fn(entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read journal: %v", err)
+	}
+	return nil
+}
+
+// Close - closes the journal file
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return errors.New("journal already closed")
+	}
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
@@ -0,0 +1,226 @@
+package journal
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testRun() Run {
+	return Run{
+		Command: "pause",
+		Project: "qa-1234-lake",
+		MetaURL: "http://127.0.0.1:8080",
+		Args:    []string{"pause", "--jobs", "a.b.c.d.e", "--chunk-size", "5"},
+		Jobs:    []string{"a.b.c.d.e", "a.b.c.d.f"},
+		Created: time.Date(2026, 10, 18, 6, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestCreateReadRun(t *testing.T) {
+	runsDir := t.TempDir()
+
+	j, err := Create(runsDir, testRun())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	want := testRun()
+	want.ID = "20261018-060000-pause"
+	if !reflect.DeepEqual(j.Run(), want) {
+		t.Errorf("Run() = %+v, want %+v", j.Run(), want)
+	}
+	if j.Dir() != filepath.Join(runsDir, want.ID) {
+		t.Errorf("Dir() = %s", j.Dir())
+	}
+
+	got, err := ReadRun(runsDir, want.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadRun = %+v, want %+v", got, want)
+	}
+}
+
+func TestCreateAssignsUniqueIDs(t *testing.T) {
+	runsDir := t.TempDir()
+
+	ids := []string{}
+	for range 3 {
+		j, err := Create(runsDir, testRun())
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, j.Run().ID)
+		j.Close()
+	}
+
+	want := []string{"20261018-060000-pause", "20261018-060000-pause-2", "20261018-060000-pause-3"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+
+	// a given id is not renamed, its run.json can only be written once
+	run := testRun()
+	run.ID = want[0]
+	if _, err := Create(runsDir, run); err == nil {
+		t.Error("Create of an existing run id succeeded")
+	}
+}
+
+func TestStatusesAfterResume(t *testing.T) {
+	runsDir := t.TempDir()
+
+	j, err := Create(runsDir, testRun())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := j.Run().ID
+	j.Record("a.b.c.d.e", StatusStarted, nil)
+	j.Record("a.b.c.d.f", StatusStarted, nil)
+	j.Record("a.b.c.d.e", StatusCompleted, nil)
+	j.Record("a.b.c.d.f", StatusFailed, errors.New("meta service: 500"))
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	resumed, err := Open(runsDir, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resumed.Close()
+
+	statuses, err := resumed.Statuses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Status{"a.b.c.d.e": StatusCompleted, "a.b.c.d.f": StatusFailed}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("Statuses() = %v, want %v", statuses, want)
+	}
+
+	// the resumed run appends to the same journal
+	resumed.Record("a.b.c.d.f", StatusStarted, nil)
+	resumed.Log = io.Discard
+	resumed.Record("a.b.c.d.f", StatusCompleted, nil)
+	statuses, err = resumed.Statuses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statuses["a.b.c.d.f"] != StatusCompleted || statuses["a.b.c.d.e"] != StatusCompleted {
+		t.Errorf("Statuses() after resume = %v, want every job completed", statuses)
+	}
+}
+
+func TestStatusesSkipsPartialLines(t *testing.T) {
+	runsDir := t.TempDir()
+
+	j, err := Create(runsDir, testRun())
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.Record("a.b.c.d.e", StatusCompleted, nil)
+
+	// a crash can leave blank lines and a partly written last line behind
+	file, err := os.OpenFile(filepath.Join(j.Dir(), journalFile), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("\n{\"time\":\"2026-10-18T06:00:00Z\",\"job\":\"a.b.c.d.f\",\"sta")
+	file.Close()
+
+	log := &strings.Builder{}
+	j.Log = log
+
+	statuses, err := j.Statuses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Status{"a.b.c.d.e": StatusCompleted}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("Statuses() = %v, want %v", statuses, want)
+	}
+	if !strings.HasPrefix(log.String(), "Skipping unreadable journal line 3:") {
+		t.Errorf("log = %q, want the partial line reported", log.String())
+	}
+
+	// resuming ends the partial line, so the entries of the resumed run are kept
+	j.Close()
+	resumed, err := Open(runsDir, j.Run().ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resumed.Close()
+	resumed.Record("a.b.c.d.f", StatusCompleted, nil)
+
+	statuses, err = resumed.Statuses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want["a.b.c.d.f"] = StatusCompleted
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("Statuses() after resume = %v, want %v", statuses, want)
+	}
+}
+
+func TestOpenMissingRun(t *testing.T) {
+	if _, err := Open(t.TempDir(), "20261018-060000-pause"); err == nil {
+		t.Error("Open of a missing run succeeded")
+	}
+}
+
+func TestStepsAfterResume(t *testing.T) {
+	runsDir := t.TempDir()
+
+	j, err := Create(runsDir, testRun())
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.Record("a.b.c.d.e", StatusStarted, nil)
+	j.RecordStep("a.b.c.d.e", 1)
+	j.RecordStep("a.b.c.d.e", 2)
+	j.Record("a.b.c.d.e", StatusFailed, errors.New("step 3/4 wait: timed out"))
+	j.Record("a.b.c.d.f", StatusStarted, nil)
+	j.Close()
+
+	resumed, err := Open(runsDir, j.Run().ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resumed.Close()
+
+	steps, err := resumed.Steps()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"a.b.c.d.e": 2}; !reflect.DeepEqual(steps, want) {
+		t.Errorf("Steps() = %v, want %v", steps, want)
+	}
+
+	// step entries do not change the status of the job
+	resumed.Record("a.b.c.d.e", StatusStarted, nil)
+	resumed.RecordStep("a.b.c.d.e", 3)
+	statuses, err := resumed.Statuses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Status{"a.b.c.d.e": StatusStarted, "a.b.c.d.f": StatusStarted}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("Statuses() = %v, want %v", statuses, want)
+	}
+
+	steps, err = resumed.Steps()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if steps["a.b.c.d.e"] != 3 {
+		t.Errorf("Steps() after resume = %v, want step 3 for a.b.c.d.e", steps)
+	}
+}
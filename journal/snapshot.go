@@ -0,0 +1,78 @@
+package journal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// snapshotsDir - directory of the run dir holding the job definitions taken before editing them
+const snapshotsDir = "snapshots"
+
+// SaveSnapshot - keeps the definition of the job as it was before the run edited it
+//
+// A job keeps its first snapshot, so resuming a run does not replace the original
+// definition with an already edited one. Returns the path of the snapshot.
+func (j *Journal) SaveSnapshot(job string, definition []byte) (string, error) {
+	dir := filepath.Join(j.dir, snapshotsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("create snapshots dir: %v", err)
+	}
+
+	path := filepath.Join(dir, job+".json")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return path, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("create snapshot: %v", err)
+	}
+
+	_, err = file.Write(definition)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("write snapshot %s: %v", path, err)
+	}
+
+	return path, nil
+}
+
+// ReadSnapshot - reads the job definition snapshotted by the run
+func ReadSnapshot(runsDir, id, job string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(runsDir, id, snapshotsDir, job+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("read snapshot of %s in run %s: %v", job, id, err)
+	}
+	return data, nil
+}
+
+// SnapshotJobs - returns the sorted jobs the run took snapshots of
+func SnapshotJobs(runsDir, id string) ([]string, error) {
+	if _, err := ReadRun(runsDir, id); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(filepath.Join(runsDir, id, snapshotsDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read snapshots of run %s: %v", id, err)
+	}
+
+	jobs := []string{}
+	for _, entry := range entries {
+		if job, ok := strings.CutSuffix(entry.Name(), ".json"); ok && !entry.IsDir() {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Strings(jobs)
+
+	return jobs, nil
+}
@@ -0,0 +1,63 @@
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// MergePatch - applies an RFC 7386 JSON merge patch to the document
+//
+// Members of patch objects replace the ones of the document, null members remove them
+// and any patch that is not an object replaces the whole document.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, fmt.Errorf("document: %v", err)
+	}
+	merge, err := decode(patch)
+	if err != nil {
+		return nil, fmt.Errorf("merge patch: %v", err)
+	}
+
+	return json.Marshal(mergeValue(target, merge))
+}
+
+// mergeValue - the MergePatch algorithm of RFC 7386 section 2
+func mergeValue(target, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = map[string]any{}
+	}
+
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = mergeValue(targetObj[key], value)
+	}
+
+	return targetObj
+}
+
+// decode - decodes json keeping numbers as they are written
+func decode(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after the json value")
+	}
+
+	return value, nil
+}
@@ -0,0 +1,381 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Operation - a single RFC 6902 JSON Patch operation
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Patch - an RFC 6902 JSON Patch document
+type Patch []Operation
+
+// ParsePatch - decodes and validates a JSON Patch document
+func ParsePatch(data []byte) (Patch, error) {
+	patch := Patch{}
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return nil, fmt.Errorf("json patch: %v", err)
+	}
+	// members - the members given in every operation, as an empty from is the root and not a missing one
+	members := []map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, fmt.Errorf("json patch: %v", err)
+	}
+
+	for i, op := range patch {
+		if _, ok := members[i]["path"]; !ok {
+			return nil, fmt.Errorf("json patch operation %d: path is required", i)
+		}
+
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return nil, fmt.Errorf("json patch operation %d: %s needs a value", i, op.Op)
+			}
+		case "move", "copy":
+			if _, ok := members[i]["from"]; !ok {
+				return nil, fmt.Errorf("json patch operation %d: %s needs a from", i, op.Op)
+			}
+			if _, err := parsePointer(op.From); err != nil {
+				return nil, fmt.Errorf("json patch operation %d: from: %v", i, err)
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("json patch operation %d: unknown op %q", i, op.Op)
+		}
+
+		if _, err := parsePointer(op.Path); err != nil {
+			return nil, fmt.Errorf("json patch operation %d: path: %v", i, err)
+		}
+	}
+
+	return patch, nil
+}
+
+// Apply - applies the operations to the document in order, the first failing one aborts the patch
+func (p Patch) Apply(doc []byte) ([]byte, error) {
+	root, err := decode(doc)
+	if err != nil {
+		return nil, fmt.Errorf("document: %v", err)
+	}
+
+	for i, op := range p {
+		root, err = op.apply(root)
+		if err != nil {
+			return nil, fmt.Errorf("json patch operation %d (%s %s): %v", i, op.Op, op.Path, err)
+		}
+	}
+
+	return json.Marshal(root)
+}
+
+// apply - applies the operation to the decoded document and returns the new root
+func (op Operation) apply(root any) (any, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add":
+		value, err := decode(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		return add(root, path, value)
+
+	case "remove":
+		root, _, err := remove(root, path)
+		return root, err
+
+	case "replace":
+		value, err := decode(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		root, _, err = remove(root, path)
+		if err != nil {
+			return nil, err
+		}
+		return add(root, path, value)
+
+	case "move":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if isPrefix(from, path) && len(from) < len(path) {
+			return nil, fmt.Errorf("can not move %s into its own child %s", op.From, op.Path)
+		}
+		root, value, err := remove(root, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(root, path, value)
+
+	case "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := get(root, from)
+		if err != nil {
+			return nil, err
+		}
+		copied, err := deepCopy(value)
+		if err != nil {
+			return nil, err
+		}
+		return add(root, path, copied)
+
+	case "test":
+		expected, err := decode(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		actual, err := get(root, path)
+		if err != nil {
+			return nil, err
+		}
+		if !equal(actual, expected) {
+			return nil, fmt.Errorf("test failed, value is %s", mustMarshal(actual))
+		}
+		return root, nil
+	}
+
+	return nil, fmt.Errorf("unknown op %q", op.Op)
+}
+
+// parsePointer - splits an RFC 6901 JSON pointer into its unescaped reference tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("json pointer %q does not start with '/'", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+// get - returns the value the path points to
+func get(root any, path []string) (any, error) {
+	value := root
+	for i, token := range path {
+		switch node := value.(type) {
+		case map[string]any:
+			child, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("%s does not exist", pointerOf(path[:i+1]))
+			}
+			value = child
+		case []any:
+			index, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", pointerOf(path[:i+1]), err)
+			}
+			value = node[index]
+		default:
+			return nil, fmt.Errorf("%s is not an object or array", pointerOf(path[:i]))
+		}
+	}
+	return value, nil
+}
+
+// add - adds the value at the path, replacing object members and inserting into arrays
+func add(root any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parent, err := get(root, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+
+	switch node := parent.(type) {
+	case map[string]any:
+		node[last] = value
+		return root, nil
+	case []any:
+		index, err := arrayIndex(last, len(node), true)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", pointerOf(path), err)
+		}
+		node = append(node[:index], append([]any{value}, node[index:]...)...)
+		return set(root, path[:len(path)-1], node)
+	}
+
+	return nil, fmt.Errorf("%s is not an object or array", pointerOf(path[:len(path)-1]))
+}
+
+// remove - removes the value at the path and returns it
+func remove(root any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, root, nil
+	}
+
+	parent, err := get(root, path[:len(path)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	last := path[len(path)-1]
+
+	switch node := parent.(type) {
+	case map[string]any:
+		value, ok := node[last]
+		if !ok {
+			return nil, nil, fmt.Errorf("%s does not exist", pointerOf(path))
+		}
+		delete(node, last)
+		return root, value, nil
+	case []any:
+		index, err := arrayIndex(last, len(node), false)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", pointerOf(path), err)
+		}
+		value := node[index]
+		node = append(node[:index:index], node[index+1:]...)
+		root, err := set(root, path[:len(path)-1], node)
+		return root, value, err
+	}
+
+	return nil, nil, fmt.Errorf("%s is not an object or array", pointerOf(path[:len(path)-1]))
+}
+
+// set - replaces the value at an existing path, used to store arrays that grew or shrank
+func set(root any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parent, err := get(root, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+
+	switch node := parent.(type) {
+	case map[string]any:
+		node[last] = value
+	case []any:
+		index, err := arrayIndex(last, len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		node[index] = value
+	}
+	return root, nil
+}
+
+// arrayIndex - parses an array index token, '-' is the end of the array when adding
+func arrayIndex(token string, length int, adding bool) (int, error) {
+	if token == "-" && adding {
+		return length, nil
+	}
+
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+
+	max := length - 1
+	if adding {
+		max = length
+	}
+	if index > max {
+		return 0, fmt.Errorf("array index %d out of bounds", index)
+	}
+
+	return index, nil
+}
+
+// pointerOf - joins reference tokens back into a JSON pointer
+func pointerOf(path []string) string {
+	escaped := make([]string, len(path))
+	for i, token := range path {
+		escaped[i] = strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+	}
+	if len(escaped) == 0 {
+		return "/"
+	}
+	return "/" + strings.Join(escaped, "/")
+}
+
+// isPrefix - reports whether prefix is an ancestor of, or equal to, path
+func isPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	return reflect.DeepEqual(prefix, path[:len(prefix)])
+}
+
+// deepCopy - copies a decoded json value
+func deepCopy(value any) (any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return decode(data)
+}
+
+// equal - compares decoded json values, numbers by value
+func equal(a, b any) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, errA := a.Float64()
+		y, errB := b.Float64()
+		return errA == nil && errB == nil && x == y
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for key, value := range a {
+			other, ok := b[key]
+			if !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// mustMarshal - marshals a decoded json value for error messages
+func mustMarshal(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
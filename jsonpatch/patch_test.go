@@ -0,0 +1,227 @@
+package jsonpatch
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPatchApply(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{
+			name:  "add object member",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/baz","value":"qux"}]`,
+			want:  `{"baz":"qux","foo":"bar"}`,
+		},
+		{
+			name:  "add replaces an existing member",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/foo","value":1}]`,
+			want:  `{"foo":1}`,
+		},
+		{
+			name:  "insert into array at index",
+			doc:   `{"foo":["bar","baz"]}`,
+			patch: `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			want:  `{"foo":["bar","qux","baz"]}`,
+		},
+		{
+			name:  "insert at the start of an array",
+			doc:   `{"foo":["bar"]}`,
+			patch: `[{"op":"add","path":"/foo/0","value":"qux"}]`,
+			want:  `{"foo":["qux","bar"]}`,
+		},
+		{
+			name:  "append to array with -",
+			doc:   `{"targetProjectIds":["prep-1"]}`,
+			patch: `[{"op":"add","path":"/targetProjectIds/-","value":"qa-2"},{"op":"add","path":"/targetProjectIds/2","value":"dev-3"}]`,
+			want:  `{"targetProjectIds":["prep-1","qa-2","dev-3"]}`,
+		},
+		{
+			name:  "add to a nested array",
+			doc:   `{"a":[{"b":[]}]}`,
+			patch: `[{"op":"add","path":"/a/0/b/-","value":{"c":true}}]`,
+			want:  `{"a":[{"b":[{"c":true}]}]}`,
+		},
+		{
+			name:  "remove object member",
+			doc:   `{"baz":"qux","foo":"bar"}`,
+			patch: `[{"op":"remove","path":"/baz"}]`,
+			want:  `{"foo":"bar"}`,
+		},
+		{
+			name:  "remove array element",
+			doc:   `{"foo":["bar","qux","baz"]}`,
+			patch: `[{"op":"remove","path":"/foo/1"}]`,
+			want:  `{"foo":["bar","baz"]}`,
+		},
+		{
+			name:  "remove last array element",
+			doc:   `{"foo":["bar","baz"]}`,
+			patch: `[{"op":"remove","path":"/foo/1"},{"op":"remove","path":"/foo/0"}]`,
+			want:  `{"foo":[]}`,
+		},
+		{
+			name:  "replace",
+			doc:   `{"baz":"qux","foo":"bar"}`,
+			patch: `[{"op":"replace","path":"/baz","value":"boo"}]`,
+			want:  `{"baz":"boo","foo":"bar"}`,
+		},
+		{
+			name:  "replace array element",
+			doc:   `{"foo":["bar","baz"]}`,
+			patch: `[{"op":"replace","path":"/foo/0","value":"qux"}]`,
+			want:  `{"foo":["qux","baz"]}`,
+		},
+		{
+			name:  "replace the root",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"replace","path":"","value":{"baz":"qux"}}]`,
+			want:  `{"baz":"qux"}`,
+		},
+		{
+			name:  "move member",
+			doc:   `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			patch: `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			want:  `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`,
+		},
+		{
+			name:  "move array element",
+			doc:   `{"foo":["all","grass","cows","eat"]}`,
+			patch: `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			want:  `{"foo":["all","cows","eat","grass"]}`,
+		},
+		{
+			name:  "move into a sibling child",
+			doc:   `{"a":{"b":1},"c":{}}`,
+			patch: `[{"op":"move","from":"/a","path":"/c/a"}]`,
+			want:  `{"c":{"a":{"b":1}}}`,
+		},
+		{
+			name:  "move to itself",
+			doc:   `{"a":{"b":1}}`,
+			patch: `[{"op":"move","from":"/a","path":"/a"}]`,
+			want:  `{"a":{"b":1}}`,
+		},
+		{
+			name:  "copy is deep",
+			doc:   `{"a":{"b":[1]}}`,
+			patch: `[{"op":"copy","from":"/a","path":"/c"},{"op":"add","path":"/c/b/-","value":2}]`,
+			want:  `{"a":{"b":[1]},"c":{"b":[1,2]}}`,
+		},
+		{
+			name:  "copy the root",
+			doc:   `{"a":1}`,
+			patch: `[{"op":"copy","from":"","path":"/b"}]`,
+			want:  `{"a":1,"b":{"a":1}}`,
+		},
+		{
+			name:  "test passes",
+			doc:   `{"baz":"qux","foo":["a",2,"c"],"n":1.0}`,
+			patch: `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2},{"op":"test","path":"/n","value":1}]`,
+			want:  `{"baz":"qux","foo":["a",2,"c"],"n":1.0}`,
+		},
+		{
+			name:  "escaped pointer tokens",
+			doc:   `{"a/b":1,"m~n":2}`,
+			patch: `[{"op":"replace","path":"/a~1b","value":3},{"op":"remove","path":"/m~0n"}]`,
+			want:  `{"a/b":3}`,
+		},
+		{
+			name:  "numbers are kept as written",
+			doc:   `{"id":12345678901234567890,"rate":1.50,"exp":1e3}`,
+			patch: `[{"op":"add","path":"/big","value":98765432109876543210}]`,
+			want:  `{"big":98765432109876543210,"exp":1e3,"id":12345678901234567890,"rate":1.50}`,
+		},
+		{
+			name:  "null value",
+			doc:   `{"a":1}`,
+			patch: `[{"op":"add","path":"/a","value":null}]`,
+			want:  `{"a":null}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := ParsePatch([]byte(tt.patch))
+			if err != nil {
+				t.Fatalf("ParsePatch: %v", err)
+			}
+			got, err := patch.Apply([]byte(tt.doc))
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Apply = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatchApplyErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		err   string
+	}{
+		{"test value differs", `{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, `test failed, value is "qux"`},
+		{"test type differs", `{"n":1}`, `[{"op":"test","path":"/n","value":"1"}]`, "test failed"},
+		{"test array order differs", `{"a":[1,2]}`, `[{"op":"test","path":"/a","value":[2,1]}]`, "test failed"},
+		{"test missing member", `{}`, `[{"op":"test","path":"/a","value":1}]`, "/a does not exist"},
+		{"test failure aborts the patch", `{"a":1}`, `[{"op":"add","path":"/b","value":2},{"op":"test","path":"/a","value":2}]`, "operation 1"},
+		{"add to a missing parent", `{}`, `[{"op":"add","path":"/a/b","value":1}]`, "/a does not exist"},
+		{"add past the end of an array", `{"a":[1]}`, `[{"op":"add","path":"/a/2","value":1}]`, "out of bounds"},
+		{"add with a leading zero index", `{"a":[1,2]}`, `[{"op":"add","path":"/a/01","value":1}]`, "invalid array index"},
+		{"remove missing member", `{}`, `[{"op":"remove","path":"/a"}]`, "/a does not exist"},
+		{"remove with -", `{"a":[1]}`, `[{"op":"remove","path":"/a/-"}]`, "invalid array index"},
+		{"replace missing member", `{}`, `[{"op":"replace","path":"/a","value":1}]`, "/a does not exist"},
+		{"move into its own child", `{"a":{"b":1}}`, `[{"op":"move","from":"/a","path":"/a/b/c"}]`, "own child"},
+		{"move missing member", `{}`, `[{"op":"move","from":"/a","path":"/b"}]`, "/a does not exist"},
+		{"path through a scalar", `{"a":1}`, `[{"op":"add","path":"/a/b","value":1}]`, "not an object or array"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := ParsePatch([]byte(tt.patch))
+			if err != nil {
+				t.Fatalf("ParsePatch: %v", err)
+			}
+			_, err = patch.Apply([]byte(tt.doc))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Apply error = %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParsePatchInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		err   string
+	}{
+		{"not an array", `{"op":"add"}`, "json patch"},
+		{"unknown op", `[{"op":"merge","path":"/a"}]`, "unknown op"},
+		{"missing path", `[{"op":"remove"}]`, "path is required"},
+		{"missing value", `[{"op":"add","path":"/a"}]`, "needs a value"},
+		{"move without from", `[{"op":"move","path":"/a"}]`, "needs a from"},
+		{"copy without from", `[{"op":"copy","path":"/a"}]`, "needs a from"},
+		{"pointer without slash", `[{"op":"remove","path":"a"}]`, "does not start with '/'"},
+		{"from without slash", `[{"op":"copy","from":"a","path":"/b"}]`, "does not start with '/'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePatch([]byte(tt.patch))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ParsePatch error = %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
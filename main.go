@@ -1,297 +1,170 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"net/http"
+	"io"
 	"os"
-	"strconv"
-	"strings"
-	"sync"
+	"path/filepath"
 
-	"github.com/mah35h95/break-time/auth"
 	"github.com/mah35h95/break-time/dice"
+	"github.com/mah35h95/break-time/journal"
 	"github.com/mah35h95/break-time/utils"
 )
 
-// CronRange - Has a min max value for a cron string
-type DeleteStorage struct {
-	Bucket          string          `json:"bucket"`
-	StoragePrefixes []StoragePrefix `json:"storagePrefixes"`
-}
-
-type StoragePrefix struct {
-	Prefix string `json:"prefix"`
-}
-
-// CronRange - Has a min max value for a cron string
-type CronRange struct {
-	Min  int
-	Max  int
-	Cron string
-}
-
 // main - everything started here
 func main() {
-	fmt.Println("Fetching ENV variables...")
-
-	jobs, ok := os.LookupEnv("JOBS")
-	if !ok || len(jobs) == 0 {
-		fmt.Println("JOBS env variable is not set in launch.json, aborting...")
+	cmd, opts, run, err := parseArgs(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
 		os.Exit(0)
 	}
-
-	project, ok := os.LookupEnv("PROJECT")
-	if !ok || len(project) == 0 {
-		fmt.Println("PROJECT env variable is not set in launch.json, aborting...")
-		os.Exit(0)
+	if err != nil {
+		fmt.Printf("%v, aborting...\n", err)
+		os.Exit(2)
 	}
-	metaSvcUrl := fmt.Sprintf("https://dice-meta-svc-dot-%s.appspot.com", project)
 
-	cmd, ok := os.LookupEnv("CMD")
-	if !ok || len(cmd) == 0 {
-		fmt.Println("CMD env variable is not set in launch.json, aborting...")
-		os.Exit(0)
+	// report commands write the report to stdout and their progress to stderr, so the report can be piped
+	var out, progress io.Writer = os.Stdout, os.Stdout
+	if cmd.report {
+		progress = os.Stderr
 	}
+	opts.Auth.Log = progress
 
-	chunkSizeString, ok := os.LookupEnv("CHUNK_SIZE")
-	if !ok || len(chunkSizeString) == 0 {
-		fmt.Println("CHUNK_SIZE env variable is not set in launch.json, hence picking the default value = 5")
-		chunkSizeString = "5"
+	if err := confirmProd(cmd, opts, progress); err != nil {
+		fmt.Fprintf(progress, "%v, aborting...\n", err)
+		os.Exit(2)
 	}
 
-	allJobIDs := strings.Split(jobs, "/")
-
-	chunkSize, err := strconv.Atoi(chunkSizeString)
+	tokens, err := newTokenSources(opts)
 	if err != nil {
-		chunkSize = 5
+		fmt.Fprintf(progress, "%v, aborting...\n", err)
+		os.Exit(2)
 	}
 
-	chunkJobIDs := utils.ChunkJobs(allJobIDs, chunkSize)
-
-	for i := range chunkJobIDs {
-		jobIDs := chunkJobIDs[i]
-
-		fmt.Println("Fetching Identity Token...")
-		bearer := auth.GetIdentityToken()
-
-		assesBearer := ""
-		if cmd == dice.CleanFS || cmd == dice.ListCurrentFS || cmd == dice.ListAllFS {
-			fmt.Println("Fetching Access Token...")
-			assesBearer = auth.GetAccessToken()
+	if opts.selector != nil {
+		opts.defaultJobs, err = selectJobs(context.Background(), newMetaClient(opts, tokens, nil, progress), opts.selector, opts.ChunkSize, progress)
+		if err == nil && len(opts.defaultJobs) == 0 {
+			err = errors.New("no job matches --select")
 		}
+		if err != nil {
+			fmt.Fprintf(progress, "%v, aborting...\n", err)
+			os.Exit(2)
+		}
+	}
 
-		// bearer, err = ValidateAndRefreshToken(metaSvcUrl, bearer)
-		// if err != nil {
-		// 	fmt.Printf("(%d/%d) Jobs have Completed\n", i*chunkSize, len(allJobIDs))
-		// 	fmt.Printf("Next run starts from => (%d/%d): %s\n", i*chunkSize+1, len(allJobIDs), jobIDs[0])
-		// 	os.Exit(1)
-		// }
-
-		wg := sync.WaitGroup{}
-		wg.Add(len(jobIDs))
-
-		for j := range jobIDs {
-			dataSourceId := jobIDs[j]
-			fmt.Printf("(%d/%d): %s - Start\n", (chunkSize*i)+j+1, len(allJobIDs), dataSourceId)
-
-			go func() {
-				body := `{}`
-				err := error(nil)
-
-				switch cmd {
-				case dice.Pause:
-					err = dice.ExecuteJobCmd(dataSourceId, metaSvcUrl, bearer, http.MethodPost, dice.Pause, body)
-
-				case dice.Resume:
-					err = dice.ExecuteJobCmd(dataSourceId, metaSvcUrl, bearer, http.MethodPost, dice.Resume, body)
-
-				case dice.Stop:
-					err = dice.ExecuteJobCmd(dataSourceId, metaSvcUrl, bearer, http.MethodPost, dice.Stop, body)
-
-				case dice.Load:
-					err = dice.ExecuteJobCmd(dataSourceId, metaSvcUrl, bearer, http.MethodPost, dice.Load, body)
-
-				case dice.Lock:
-					err = dice.ExecuteJobCmd(dataSourceId, metaSvcUrl, bearer, http.MethodPost, dice.Lock, body)
-
-				case dice.Unlock:
-					err = dice.ExecuteJobCmd(dataSourceId, metaSvcUrl, bearer, http.MethodPost, dice.Unlock, body)
-
-				case dice.Reload:
-					body = `{"keepFoundryDataset": true,"retainData": false}`
-					err = dice.ExecuteJobCmd(dataSourceId, metaSvcUrl, bearer, http.MethodPost, dice.Reload, body)
-
-				case dice.EditGCPTarget:
-					body = `{"targetProjectIds": ["prep-2134-entdatalake-969cbf","qa-2134-entdatalake-d057be"],"jdbcTargets": []}`
-					err = dice.ExecuteJobCmd(dataSourceId, metaSvcUrl, bearer, http.MethodPost, dice.Edit, body)
-
-				case dice.ToNewLake:
-					if strings.HasPrefix(dataSourceId, "bigquery-source.bigquery.prod_2434_entdataingest_05104f.") {
-						err = dice.ExecuteJobCmd(dataSourceId, metaSvcUrl, bearer, http.MethodPost, dice.Stop, body)
-						if err != nil {
-							return
-						}
-					}
-
-					body = `{"newLakeJob":true}`
-					err = dice.ExecuteJobCmd(dataSourceId, metaSvcUrl, bearer, http.MethodPost, dice.Edit, body)
-
-				case dice.FromNewLake:
-					body = `{"newLakeJob":false}`
-					err = dice.ExecuteJobCmd(dataSourceId, metaSvcUrl, bearer, http.MethodPost, dice.Edit, body)
-
-				case dice.Delete:
-					err = dice.DeleteJob(dataSourceId, metaSvcUrl, bearer)
-
-				case dice.EditCron:
-					cron, cronTimeZone := getCron((chunkSize * i) + j + 1)
-					err = dice.EditCronSchedule(dataSourceId, metaSvcUrl, bearer, cron, cronTimeZone)
-
-				case dice.DeleteHydratedRes:
-					err = dice.DeleteHydratedResources(dataSourceId, metaSvcUrl, bearer)
-
-				case dice.CleanFS:
-					bucketName := fmt.Sprintf("%s-dice-fs", project)
-
-					dirs := utils.GetTransactionsDirs(bucketName, dataSourceId, assesBearer)
-					deleteChunk := 100
-
-					for i := 0; i < len(dirs); {
-						dirDeleteReq := DeleteStorage{
-							Bucket:          bucketName,
-							StoragePrefixes: []StoragePrefix{},
-						}
+	j, jobs, total, err := planRun(cmd, opts, os.Args[1:], progress)
+	if err != nil {
+		fmt.Fprintf(progress, "%v, aborting...\n", err)
+		os.Exit(2)
+	}
 
-						for j := 0; i < len(dirs) && j < deleteChunk; j++ {
-							dirDeleteReq.StoragePrefixes = append(
-								dirDeleteReq.StoragePrefixes,
-								StoragePrefix{Prefix: dirs[i]},
-							)
-							i++
-						}
+	dryRunOut := io.Discard
+	if opts.DryRun {
+		file, err := os.OpenFile(filepath.Join(j.Dir(), dryRunFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			fmt.Fprintf(progress, "%v, aborting...\n", err)
+			os.Exit(2)
+		}
+		defer file.Close()
+		dryRunOut = file
+		fmt.Fprintf(progress, "Dry run, nothing will be changed. Planned requests: %s\n", file.Name())
+	}
 
-						byteBody, err := json.Marshal(dirDeleteReq)
-						if err != nil {
-							fmt.Printf("JSON Marshal: %+v\n", err)
-							os.Exit(0)
-						}
+	rc := newRunContext(opts, tokens, j, dryRunOut, out, progress)
 
-						body := string(byteBody)
-						err = dice.ExecuteJobCmd(dataSourceId, metaSvcUrl, bearer, http.MethodPost, dice.DeleteStorage, body)
-						if err != nil {
-							fmt.Println(err)
-						}
+	dispatchCtx, jobCtx, stop := handleSignals(opts.ShutdownTimeout, progress)
+	defer stop()
 
-						if len(dirs) > deleteChunk {
-							fmt.Println("Fetching Identity Token...")
-							bearer = auth.GetIdentityToken()
-						}
-					}
-				case dice.ListCurrentFS:
-					bucketName := fmt.Sprintf("%s-dice-fs", project)
-					dirs := utils.GetCurrentDirs(bucketName, dataSourceId, assesBearer)
+	results := utils.RunPool(dispatchCtx, jobs, opts.ChunkSize, func(i int, job plannedJob) error {
+		fmt.Fprintf(progress, "(%d/%d): %s - Start\n", job.N, total, job.ID)
+		record(progress, j, job.ID, journal.StatusStarted, nil)
 
-					if len(dirs) > 0 {
-						fmt.Printf("Excess (%d) folders in %s\n", len(dirs)-2, dataSourceId)
-					}
+		if err := run(jobCtx, rc, job.N, job.ID); err != nil {
+			record(progress, j, job.ID, journal.StatusFailed, err)
+			fmt.Fprintf(progress, "(%d/%d): %s - Failed: %v\n", job.N, total, job.ID, err)
+			return err
+		}
 
-				case dice.ListAllFS:
-					bucketName := fmt.Sprintf("%s-dice-fs", project)
-					dirs := []string{}
-					dirs = append(dirs, utils.GetTransactionsDirs(bucketName, dataSourceId, assesBearer)...)
-					dirs = append(dirs, utils.GetCurrentDirs(bucketName, dataSourceId, assesBearer)...)
-					dirs = append(dirs, utils.GetDeltaDirs(bucketName, dataSourceId, assesBearer)...)
+		record(progress, j, job.ID, journal.StatusCompleted, nil)
+		fmt.Fprintf(progress, "(%d/%d): %s - Complete\n", job.N, total, job.ID)
+		return nil
+	})
 
-					if len(dirs) > 0 {
-						err := utils.WriteToFile(
-							fmt.Sprintf("./jobs/%s.log", dataSourceId),
-							[]byte(strings.Join(dirs, "\n")+"\n"),
-						)
-						if err != nil {
-							fmt.Println("Error writing file:", err)
-							return
-						}
-						fmt.Printf("Data written successfully for %s\n", dataSourceId)
-					}
+	interrupted := dispatchCtx.Err() != nil
+	if !interrupted {
+		fmt.Fprintln(progress, "All jobs execution complete!")
+	}
 
-				default:
-					fmt.Println("CMD provided does not match with predefined cases, aborting...")
-					os.Exit(0)
-				}
+	finishErr := rc.runFinish()
+	if finishErr != nil {
+		fmt.Fprintln(progress, finishErr)
+	}
 
-				if err != nil {
-					fmt.Println(err)
-				}
+	failed := printSummary(progress, results, interrupted)
+	if failed > 0 || interrupted {
+		fmt.Fprintf(progress, "Retry the failed and not started jobs with: break-time %s --resume %s\n", cmd.name, j.Run().ID)
+	}
 
-				fmt.Printf("(%d/%d): %s - Complete\n", (chunkSize*i)+j+1, len(allJobIDs), dataSourceId)
-				wg.Done()
-			}()
-		}
+	j.Close()
+	stop()
 
-		wg.Wait()
+	switch {
+	case interrupted:
+		os.Exit(130)
+	case failed > 0 || finishErr != nil:
+		os.Exit(1)
 	}
-
-	fmt.Println("All jobs execution complete!")
 }
 
-// ValidateAndRefreshToken - validates and refreshed token when required for every batch
-func ValidateAndRefreshToken(metaSvcUrl, bearer string) (string, error) {
-	newBearer := bearer
-	retryCount := 5
-
-	for i := range retryCount {
-		err := dice.ValidateToken(metaSvcUrl, newBearer)
-
-		if err != nil {
-			fmt.Println(err)
-
-			if err.Error() == "403" {
-				fmt.Printf("Updating Identity Token...(%d)\n", i+1)
-				newBearer = auth.GetIdentityToken()
-				continue
-			}
+// record - writes the job status to the journal, a failing journal only gets reported to progress
+func record(progress io.Writer, j *journal.Journal, id dice.DataSourceID, status journal.Status, jobErr error) {
+	if err := j.Record(id.String(), status, jobErr); err != nil {
+		fmt.Fprintf(progress, "Journal: %v\n", err)
+	}
+}
 
-			return newBearer, err
+// printSummary - prints the completed, failed and not started jobs to w, returns the failed count
+//
+// Completed and not started jobs are only listed when the run was interrupted.
+func printSummary(w io.Writer, results []utils.Result[plannedJob], interrupted bool) int {
+	completed, failed, notStarted := []string{}, []string{}, []string{}
+	for _, result := range results {
+		switch {
+		case !result.Started:
+			notStarted = append(notStarted, result.Item.ID.String())
+		case result.Err != nil:
+			failed = append(failed, fmt.Sprintf("%s: %v", result.Item.ID, result.Err))
+		default:
+			completed = append(completed, result.Item.ID.String())
 		}
+	}
 
-		return newBearer, nil
+	fmt.Fprintf(
+		w, "Completed: %d, Failed: %d, Not started: %d, Total: %d\n",
+		len(completed), len(failed), len(notStarted), len(results),
+	)
+
+	if interrupted {
+		printList(w, "Completed jobs:", completed)
+	}
+	printList(w, "Failed jobs:", failed)
+	if interrupted {
+		printList(w, "Not started jobs:", notStarted)
 	}
 
-	fmt.Printf("Failed to update Identity Token for %d times\nExiting...\n", retryCount)
-	return newBearer, fmt.Errorf("unable to refresh identity token")
+	return len(failed)
 }
 
-// getCron - returns an increasing cron string
-func getCron(value int) (string, string) {
-	cronTimeZone := "America/Chicago"
-
-	cronRanges := []CronRange{
-		{Min: 1, Max: 50, Cron: "0 0 * * *"},
-		{Min: 51, Max: 100, Cron: "30 0 * * *"},
-		{Min: 101, Max: 150, Cron: "0 1 * * *"},
-		{Min: 151, Max: 200, Cron: "30 1 * * *"},
-		{Min: 201, Max: 250, Cron: "0 2 * * *"},
-		{Min: 251, Max: 300, Cron: "30 2 * * *"},
-		{Min: 301, Max: 350, Cron: "0 3 * * *"},
-		{Min: 351, Max: 400, Cron: "30 3 * * *"},
-		{Min: 401, Max: 450, Cron: "0 4 * * *"},
-		{Min: 451, Max: 500, Cron: "30 4 * * *"},
-		{Min: 501, Max: 550, Cron: "0 5 * * *"},
-		{Min: 551, Max: 600, Cron: "30 5 * * *"},
-		{Min: 601, Max: 650, Cron: "0 6 * * *"},
-		{Min: 651, Max: 700, Cron: "30 6 * * *"},
-		{Min: 701, Max: 750, Cron: "0 7 * * *"},
-		{Min: 751, Max: 800, Cron: "30 7 * * *"},
-		{Min: 801, Max: 850, Cron: "0 8 * * *"},
-		{Min: 851, Max: 900, Cron: "30 8 * * *"},
+// printList - prints a titled list to w, nothing when empty
+func printList(w io.Writer, title string, lines []string) {
+	if len(lines) == 0 {
+		return
 	}
 
-	for _, cronRange := range cronRanges {
-		if value >= cronRange.Min && value <= cronRange.Max {
-			return cronRange.Cron, cronTimeZone
-		}
+	fmt.Fprintln(w, title)
+	for _, line := range lines {
+		fmt.Fprintf(w, "  %s\n", line)
 	}
-
-	return "0 0 * * *", cronTimeZone
 }
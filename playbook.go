@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mah35h95/break-time/dice"
+	"gopkg.in/yaml.v3"
+)
+
+// playbookCmd - dice command of the playbook subcommand
+const playbookCmd = "playbook"
+
+// playbookReportFile - csv of the step every job of a playbook run reached, inside the run dir
+const playbookReportFile = "playbook-report.csv"
+
+// waitStep - step command that only polls the job until it reaches the wait states
+const waitStep = "wait"
+
+// playbookCmds - commands a playbook step can run
+var playbookCmds = []string{
+	dice.Lock, dice.Unlock, dice.Pause, dice.Resume, dice.Stop, dice.Load, dice.Reload,
+	dice.Edit, dice.DeleteHydratedRes, dice.Delete, waitStep,
+}
+
+// playbook - ordered steps run for every job
+type playbook struct {
+	Name  string         `yaml:"name"`
+	Steps []playbookStep `yaml:"steps"`
+}
+
+// playbookStep - a single step of a playbook
+type playbookStep struct {
+	// Name - shown in the output and the report, defaults to the command
+	Name string `yaml:"name"`
+	Cmd  string `yaml:"cmd"`
+	// Body - posted to the command, required for edit, the reload options for reload
+	Body any `yaml:"body"`
+	// When - --select like terms on the job definition, the step is skipped unless all match
+	When []string      `yaml:"when"`
+	Wait *playbookWait `yaml:"wait"`
+
+	body   string
+	when   *selector
+	waiter *waiter
+}
+
+// playbookWait - polling after a step, "wait: true" uses the defaults of the command
+type playbookWait struct {
+	States      []string      `yaml:"states"`
+	Timeout     time.Duration `yaml:"timeout"`
+	Interval    time.Duration `yaml:"interval"`
+	MaxInterval time.Duration `yaml:"maxInterval"`
+}
+
+// UnmarshalYAML - accepts a bool as well as the wait fields
+func (w *playbookWait) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		enabled := false
+		if err := node.Decode(&enabled); err != nil {
+			return err
+		}
+		if !enabled {
+			return errors.New("wait: false is not supported, leave wait out instead")
+		}
+		*w = playbookWait{}
+		return nil
+	}
+
+	type fields playbookWait
+	return node.Decode((*fields)(w))
+}
+
+// loadPlaybook - reads and validates a YAML or JSON playbook
+func loadPlaybook(file string) (*playbook, error) {
+	if file == "" {
+		return nil, errors.New("--file is required")
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("--file: %v", err)
+	}
+
+	pb := &playbook{}
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(pb); err != nil {
+		return nil, fmt.Errorf("playbook %s: %v", file, err)
+	}
+	if len(pb.Steps) == 0 {
+		return nil, fmt.Errorf("playbook %s has no steps", file)
+	}
+
+	errs := []error{}
+	for i := range pb.Steps {
+		if err := pb.Steps[i].prepare(); err != nil {
+			errs = append(errs, fmt.Errorf("step %d: %v", i+1, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("playbook %s: %v", file, errors.Join(errs...))
+	}
+	return pb, nil
+}
+
+// prepare - validates the step and resolves its body, condition and wait
+func (s *playbookStep) prepare() error {
+	s.Cmd = strings.ReplaceAll(strings.TrimSpace(s.Cmd), "-", "_")
+	if !slices.Contains(playbookCmds, s.Cmd) {
+		names := make([]string, len(playbookCmds))
+		for i, cmd := range playbookCmds {
+			names[i] = cliName(cmd)
+		}
+		return fmt.Errorf("cmd must be one of %s, got %q", strings.Join(names, ", "), s.Cmd)
+	}
+	if s.Name == "" {
+		s.Name = cliName(s.Cmd)
+	}
+
+	errs := []error{}
+
+	switch {
+	case s.Body != nil && s.Cmd == waitStep:
+		errs = append(errs, errors.New("wait steps take no body"))
+	case s.Body != nil:
+		body, err := json.Marshal(s.Body)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("body: %v", err))
+		}
+		s.body = string(body)
+	case s.Cmd == dice.Edit:
+		errs = append(errs, errors.New("edit steps need a body"))
+	case s.Cmd == dice.Reload:
+		body, _ := json.Marshal(dice.ReloadOptions{KeepFoundryDataset: true})
+		s.body = string(body)
+	default:
+		s.body = `{}`
+	}
+
+	if len(s.When) > 0 {
+		s.when = &selector{}
+		for _, raw := range s.When {
+			term, err := parseSelectTerm(raw)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("when %v", err))
+				continue
+			}
+			if term.key == "name" && (term.op == "=" || term.op == "!=") {
+				if _, err := path.Match(term.value, ""); err != nil {
+					errs = append(errs, fmt.Errorf("when %q: %v", raw, err))
+					continue
+				}
+			}
+			s.when.filters = append(s.when.filters, term)
+		}
+	}
+
+	if s.Wait == nil && s.Cmd == waitStep {
+		errs = append(errs, errors.New("wait steps need wait.states"))
+	}
+	if s.Wait != nil {
+		states := s.Wait.States
+		if len(states) == 0 {
+			states = waitStates[s.Cmd]
+		}
+		if len(states) == 0 {
+			errs = append(errs, fmt.Errorf("wait.states is required, %s has no default states", cliName(s.Cmd)))
+		}
+		s.waiter = &waiter{
+			enabled:     true,
+			states:      strings.Join(states, ","),
+			timeout:     orDefault(s.Wait.Timeout, 10*time.Minute),
+			interval:    orDefault(s.Wait.Interval, 5*time.Second),
+			maxInterval: orDefault(s.Wait.MaxInterval, time.Minute),
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// orDefault - returns fallback for unset durations
+func orDefault(d, fallback time.Duration) time.Duration {
+	if d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+// run - runs the step for the job, skipped is true when the when terms do not match
+func (s *playbookStep) run(ctx context.Context, rc *runContext, id dice.DataSourceID) (skipped bool, err error) {
+	if s.when != nil {
+		definition, err := getDefinition(ctx, rc, id)
+		if err != nil {
+			return false, err
+		}
+		if !s.when.matches(id, definition) {
+			fmt.Fprintf(rc.progress, "Job %s skipped step %s, when %s does not match\n", id, s.Name, strings.Join(s.When, ", "))
+			return true, nil
+		}
+	}
+
+	switch s.Cmd {
+	case waitStep:
+	case dice.Edit:
+		err = editJob(ctx, rc, id, patch(s.body))
+	case dice.Delete:
+		if err = rc.client.Delete(ctx, id); err == nil {
+			rc.triggered(id, "to be deleted")
+		}
+	default:
+		if err = rc.client.ExecuteJobCmd(ctx, id, s.Cmd, s.body); err == nil {
+			rc.triggered(id, "to be "+s.Cmd)
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return false, s.waiter.wait(ctx, rc, id)
+}
+
+// getDefinition - returns the current job definition
+func getDefinition(ctx context.Context, rc *runContext, id dice.DataSourceID) (map[string]any, error) {
+	body, err := rc.client.GetJob(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get job: %v", err)
+	}
+
+	definition := map[string]any{}
+	if err := json.Unmarshal(body, &definition); err != nil {
+		return nil, fmt.Errorf("decode job %s: %v", id, err)
+	}
+	return definition, nil
+}
+
+// playbookProgress - the step a job of a playbook run reached
+type playbookProgress struct {
+	job     string
+	step    int
+	name    string
+	result  string
+	skipped []string
+}
+
+// playbookReport - collects the progress of the jobs of a playbook run
+type playbookReport struct {
+	once     sync.Once
+	mu       sync.Mutex
+	progress map[int]playbookProgress
+	// completed - last step every job completed in the journal, jobs of a resumed run start after it
+	completed    map[string]int
+	completedErr error
+}
+
+func setupPlaybook(fs *flag.FlagSet) (jobFunc, checkFunc) {
+	file := fs.String("file", "", "YAML or JSON playbook with the steps run for every job, required. Resumed runs continue every job after the last step it completed")
+
+	loadSteps := sync.OnceValues(func() (*playbook, error) {
+		return loadPlaybook(*file)
+	})
+	check := func() error {
+		_, err := loadSteps()
+		return err
+	}
+	r := &playbookReport{progress: map[int]playbookProgress{}}
+
+	return func(ctx context.Context, rc *runContext, n int, id dice.DataSourceID) error {
+		pb, err := loadSteps()
+		if err != nil {
+			return err
+		}
+		r.once.Do(func() {
+			r.completed, r.completedErr = rc.journal.Steps()
+			rc.atFinish(func() error {
+				return r.print(rc, pb)
+			})
+		})
+		if r.completedErr != nil {
+			return r.completedErr
+		}
+
+		progress := playbookProgress{job: id.String(), result: "done"}
+		defer func() {
+			r.mu.Lock()
+			r.progress[n] = progress
+			r.mu.Unlock()
+		}()
+
+		start := min(r.completed[id.String()], len(pb.Steps))
+		if start > 0 {
+			progress.step, progress.name = start, pb.Steps[start-1].Name
+			fmt.Fprintf(rc.progress, "Job %s completed steps 1-%d before, resuming after %s\n", id, start, progress.name)
+		}
+
+		for i := start; i < len(pb.Steps); i++ {
+			step := &pb.Steps[i]
+			progress.step, progress.name = i+1, step.Name
+			fmt.Fprintf(rc.progress, "Job %s step %d/%d %s\n", id, i+1, len(pb.Steps), step.Name)
+
+			skipped, err := step.run(ctx, rc, id)
+			if err != nil {
+				progress.result = "failed: " + err.Error()
+				return fmt.Errorf("step %d/%d %s: %v", i+1, len(pb.Steps), step.Name, err)
+			}
+			if skipped {
+				progress.skipped = append(progress.skipped, step.Name)
+			}
+			if err := rc.journal.RecordStep(id.String(), i+1); err != nil {
+				fmt.Fprintf(rc.progress, "Journal: %v\n", err)
+			}
+		}
+		return nil
+	}, check
+}
+
+// print - lists the step every job reached in job list order and writes the csv
+func (r *playbookReport) print(rc *runContext, pb *playbook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	positions := []int{}
+	for n := range r.progress {
+		positions = append(positions, n)
+	}
+	slices.Sort(positions)
+
+	title := "Playbook"
+	if pb.Name != "" {
+		title = "Playbook " + pb.Name
+	}
+	fmt.Fprintf(rc.progress, "%s, step reached by every job:\n", title)
+
+	tw := tabwriter.NewWriter(rc.progress, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "JOB\tSTEP\tRESULT\tSKIPPED")
+	rows := [][]string{{"job", "step", "step_name", "result", "skipped"}}
+	for _, n := range positions {
+		progress := r.progress[n]
+		fmt.Fprintf(
+			tw, "%s\t%d/%d %s\t%s\t%s\n",
+			progress.job, progress.step, len(pb.Steps), progress.name, progress.result,
+			orDash(strings.Join(progress.skipped, ",")),
+		)
+		rows = append(rows, []string{
+			progress.job, strconv.Itoa(progress.step), progress.name, progress.result,
+			strings.Join(progress.skipped, " "),
+		})
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	path := filepath.Join(rc.journal.Dir(), playbookReportFile)
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create report: %v", err)
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	w.WriteAll(rows)
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("write report %s: %v", path, err)
+	}
+	fmt.Fprintf(rc.progress, "Playbook report: %s\n", path)
+	return nil
+}
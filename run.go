@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/mah35h95/break-time/auth"
+	"github.com/mah35h95/break-time/dice"
+	"github.com/mah35h95/break-time/journal"
+	"github.com/mah35h95/break-time/transport"
+	"github.com/mah35h95/break-time/utils"
+)
+
+// dryRunFile - file the planned requests of a dry run are written to, inside the run dir
+const dryRunFile = "dry-run.jsonl"
+
+// tokenSources - identity token for the meta service and access token for GCS
+type tokenSources struct {
+	identity *auth.CachingTokenSource
+	access   *auth.CachingTokenSource
+}
+
+// newTokenSources - builds the token sources of the credential backend
+func newTokenSources(opts options) (tokenSources, error) {
+	identity, err := auth.NewIdentityTokenSource(opts.Auth)
+	if err != nil {
+		return tokenSources{}, err
+	}
+	access, err := auth.NewAccessTokenSource(opts.Auth)
+	if err != nil {
+		return tokenSources{}, err
+	}
+	return tokenSources{identity: identity, access: access}, nil
+}
+
+// newMetaClient - builds the rate limited, retrying meta service client, retries and refreshes are reported to progress
+//
+// Requests other than GETs are written to dryRun instead of being sent when it is set.
+func newMetaClient(opts options, tokens tokenSources, dryRun, progress io.Writer) *dice.Client {
+	return dice.NewClient(dice.Config{
+		BaseURL: opts.MetaSvcUrl(),
+		Tokens:  tokens.identity,
+		HTTPClient: &http.Client{
+			Timeout: opts.RequestTimeout,
+			Transport: transport.Retrying(
+				transport.RateLimited(nil, transport.NewLimiter("meta service", opts.MetaRPS, opts.MetaBurst, progress)),
+				opts.Retry,
+				progress,
+			),
+		},
+		Retry:    opts.Retry,
+		CmdRetry: opts.CmdRetryPolicies(),
+		SafeCmds: opts.SafeCmds(),
+		DryRun:   dryRun,
+		Log:      progress,
+	})
+}
+
+// newRunContext - builds the meta service and GCS clients of a run
+//
+// Reports are written to out and everything else to progress, requests of a dry run are
+// printed there too and written to dryRunOut.
+func newRunContext(opts options, tokens tokenSources, j *journal.Journal, dryRunOut, out, progress io.Writer) *runContext {
+	var dryRun io.Writer
+	if opts.DryRun {
+		dryRun = io.MultiWriter(progress, dryRunOut)
+	}
+
+	return &runContext{
+		opts:     opts,
+		journal:  j,
+		out:      out,
+		progress: progress,
+		client:   newMetaClient(opts, tokens, dryRun, progress),
+		fs: &utils.DiceFS{
+			Bucket: opts.BucketName(),
+			Tokens: tokens.access,
+			HTTPClient: &http.Client{
+				Timeout: opts.RequestTimeout,
+				Transport: transport.Retrying(
+					transport.RateLimited(nil, transport.NewLimiter("storage", opts.StorageRPS, opts.StorageBurst, progress)),
+					opts.Retry,
+					progress,
+				),
+			},
+			Log: progress,
+		},
+	}
+}
+
+// plannedJob - a job of the run along with its 1 based position in the run
+type plannedJob struct {
+	N  int
+	ID dice.DataSourceID
+}
+
+// planRun - creates the journal of a new run, or opens the resumed one and leaves out its completed jobs
+//
+// Returns the jobs to run and the total job count of the run, the plan is reported to progress.
+func planRun(cmd *command, opts options, args []string, progress io.Writer) (*journal.Journal, []plannedJob, int, error) {
+	if opts.Resume == "" {
+		ids, err := opts.JobIDs(progress)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+
+		jobs := make([]string, len(ids))
+		planned := make([]plannedJob, len(ids))
+		for i, id := range ids {
+			jobs[i] = id.String()
+			planned[i] = plannedJob{N: i + 1, ID: id}
+		}
+
+		j, err := journal.Create(opts.RunsDir, journal.Run{
+			Command: cmd.name,
+			Project: opts.Project,
+			MetaURL: opts.MetaURL,
+			Args:    args,
+			Jobs:    jobs,
+			DryRun:  opts.DryRun,
+		})
+		if err != nil {
+			return nil, nil, 0, err
+		}
+
+		j.Log = progress
+
+		fmt.Fprintf(progress, "Run %s journal: %s\n", j.Run().ID, j.Dir())
+		return j, planned, len(ids), nil
+	}
+
+	j, err := journal.Open(opts.RunsDir, opts.Resume)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	j.Log = progress
+
+	statuses, err := j.Statuses()
+	if err != nil {
+		j.Close()
+		return nil, nil, 0, err
+	}
+
+	run := j.Run()
+	planned := []plannedJob{}
+	for i, job := range run.Jobs {
+		if statuses[job] == journal.StatusCompleted {
+			continue
+		}
+
+		id, err := dice.ParseDataSourceID(job)
+		if err != nil {
+			j.Close()
+			return nil, nil, 0, err
+		}
+		planned = append(planned, plannedJob{N: i + 1, ID: id})
+	}
+
+	fmt.Fprintf(
+		progress, "Resuming run %s: %d of %d jobs left to run, %d already completed\n",
+		run.ID, len(planned), len(run.Jobs), len(run.Jobs)-len(planned),
+	)
+	return j, planned, len(run.Jobs), nil
+}
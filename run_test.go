@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/mah35h95/break-time/dice"
+	"github.com/mah35h95/break-time/journal"
+)
+
+func TestPlanRunResumeSkipsCompletedJobs(t *testing.T) {
+	cmd := findCommand("pause")
+	opts := options{
+		Project: "qa-1234-lake",
+		RunsDir: t.TempDir(),
+		Jobs:    "src.bq.db.s.t1/src.bq.db.s.t2/src.bq.db.s.t3",
+	}
+
+	j, planned, total, err := planRun(cmd, opts, []string{"pause", "--jobs", opts.Jobs}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(planned) != 3 || total != 3 {
+		t.Fatalf("planned %d of %d jobs, want 3 of 3", len(planned), total)
+	}
+	j.Record("src.bq.db.s.t1", journal.StatusCompleted, nil)
+	j.Record("src.bq.db.s.t2", journal.StatusFailed, nil)
+	j.Close()
+
+	opts.Jobs = ""
+	opts.Resume = j.Run().ID
+	resumed, planned, total, err := planRun(cmd, opts, nil, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resumed.Close()
+
+	want := []plannedJob{
+		{N: 2, ID: dice.DataSourceID{Source: "src", Technology: "bq", Database: "db", Schema: "s", Table: "t2"}},
+		{N: 3, ID: dice.DataSourceID{Source: "src", Technology: "bq", Database: "db", Schema: "s", Table: "t3"}},
+	}
+	if !slices.Equal(planned, want) || total != 3 {
+		t.Errorf("resumed run planned %v of %d jobs, want %v of 3", planned, total, want)
+	}
+}
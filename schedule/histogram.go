@@ -0,0 +1,89 @@
+package schedule
+
+import (
+	"time"
+
+	"github.com/mah35h95/break-time/cron"
+)
+
+// Bucket - fires of the jobs within a time bucket of a Histogram
+type Bucket struct {
+	Start time.Time
+	Fires int
+	// Jobs - ids of the jobs firing in the bucket, once per fire
+	Jobs    []string
+	Hotspot bool
+}
+
+// Histogram - fire counts of jobs per time bucket over a window
+type Histogram struct {
+	Start   time.Time
+	Window  time.Duration
+	Bucket  time.Duration
+	Buckets []Bucket
+}
+
+// NewHistogram - returns an empty histogram of the window starting at start, in UTC
+func NewHistogram(start time.Time, window, bucket time.Duration) *Histogram {
+	start = start.UTC()
+	h := &Histogram{Start: start, Window: window, Bucket: bucket}
+
+	for offset := time.Duration(0); offset < window; offset += bucket {
+		h.Buckets = append(h.Buckets, Bucket{Start: start.Add(offset), Jobs: []string{}})
+	}
+	return h
+}
+
+// Add - counts every fire of the job schedule within the window, the schedule runs in loc
+//
+// Returns the number of fires counted.
+func (h *Histogram) Add(job string, schedule *cron.Schedule, loc *time.Location) int {
+	end := h.Start.Add(h.Window)
+	fires := 0
+
+	t := h.Start.Add(-time.Minute).In(loc)
+	for {
+		t = schedule.Next(t)
+		if t.IsZero() || !t.Before(end) {
+			return fires
+		}
+
+		bucket := &h.Buckets[int(t.Sub(h.Start)/h.Bucket)]
+		bucket.Fires++
+		bucket.Jobs = append(bucket.Jobs, job)
+		fires++
+	}
+}
+
+// FlagHotspots - flags the buckets with at least factor times the mean fires of the buckets
+// with any fire, returns the mean
+//
+// Empty buckets are left out of the mean, so a few busy slots in a quiet day do not
+// all show up as hotspots.
+func (h *Histogram) FlagHotspots(factor float64) float64 {
+	total, busy := 0, 0
+	for _, bucket := range h.Buckets {
+		total += bucket.Fires
+		if bucket.Fires > 0 {
+			busy++
+		}
+	}
+	if busy == 0 {
+		return 0
+	}
+
+	mean := float64(total) / float64(busy)
+	for i := range h.Buckets {
+		h.Buckets[i].Hotspot = float64(h.Buckets[i].Fires) >= factor*mean
+	}
+	return mean
+}
+
+// Max - returns the highest fire count of a bucket
+func (h *Histogram) Max() int {
+	highest := 0
+	for _, bucket := range h.Buckets {
+		highest = max(highest, bucket.Fires)
+	}
+	return highest
+}
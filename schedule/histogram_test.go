@@ -0,0 +1,130 @@
+package schedule
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mah35h95/break-time/cron"
+)
+
+func mustParse(t *testing.T, expr string) *cron.Schedule {
+	t.Helper()
+	s, err := cron.Parse(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := cron.LoadTimezone(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestNewHistogram(t *testing.T) {
+	chicago := mustLoad(t, "America/Chicago")
+	h := NewHistogram(time.Date(2026, 1, 14, 18, 0, 0, 0, chicago), 24*time.Hour, 30*time.Minute)
+
+	if want := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC); !h.Start.Equal(want) || h.Start.Location() != time.UTC {
+		t.Errorf("Start = %s, want %s", h.Start, want)
+	}
+	if len(h.Buckets) != 48 {
+		t.Fatalf("%d buckets, want 48", len(h.Buckets))
+	}
+	if last := h.Buckets[47].Start; !last.Equal(time.Date(2026, 1, 15, 23, 30, 0, 0, time.UTC)) {
+		t.Errorf("last bucket starts at %s", last)
+	}
+}
+
+func TestHistogramAddNormalizesToUTC(t *testing.T) {
+	chicago := mustLoad(t, "America/Chicago")
+
+	tests := []struct {
+		name   string
+		start  time.Time
+		expr   string
+		loc    *time.Location
+		fires  int
+		bucket []int
+	}{
+		{"standard time", time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC), "0 6 * * *", chicago, 1, []int{24}},
+		{"daylight saving time", time.Date(2026, 7, 15, 0, 0, 0, 0, time.UTC), "0 6 * * *", chicago, 1, []int{22}},
+		{"utc schedule", time.Date(2026, 7, 15, 0, 0, 0, 0, time.UTC), "45 6 * * *", time.UTC, 1, []int{13}},
+		{"fire at the window start counts", time.Date(2026, 7, 15, 0, 0, 0, 0, time.UTC), "0 0 * * *", time.UTC, 1, []int{0}},
+		{"every 20 minutes", time.Date(2026, 7, 15, 0, 0, 0, 0, time.UTC), "*/20 0 * * *", time.UTC, 3, []int{0, 0, 1}},
+		{"outside the window", time.Date(2026, 7, 15, 0, 0, 0, 0, time.UTC), "0 0 1 1 *", time.UTC, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHistogram(tt.start, 24*time.Hour, 30*time.Minute)
+			if got := h.Add("a.b.c.d.e", mustParse(t, tt.expr), tt.loc); got != tt.fires {
+				t.Fatalf("Add = %d fires, want %d", got, tt.fires)
+			}
+
+			got := []int{}
+			for i, bucket := range h.Buckets {
+				for range bucket.Fires {
+					got = append(got, i)
+				}
+				if len(bucket.Jobs) != bucket.Fires {
+					t.Errorf("bucket %d has %d fires and %d jobs", i, bucket.Fires, len(bucket.Jobs))
+				}
+			}
+			if !slices.Equal(got, tt.bucket) {
+				t.Errorf("fires in buckets %v, want %v", got, tt.bucket)
+			}
+		})
+	}
+}
+
+func TestHistogramWeekWindow(t *testing.T) {
+	// monday 2026-10-19
+	h := NewHistogram(time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), 7*24*time.Hour, time.Hour)
+	if got := h.Add("a.b.c.d.e", mustParse(t, "30 23 * * mon-fri"), mustLoad(t, "America/Chicago")); got != 5 {
+		t.Errorf("Add = %d fires, want 5", got)
+	}
+	// 23:30 CDT is 04:30 UTC the next day, so the first fire is tuesday
+	if h.Buckets[24+4].Fires != 1 || h.Buckets[4].Fires != 0 {
+		t.Errorf("the monday evening fire is not counted on tuesday 04:00 UTC")
+	}
+}
+
+func TestFlagHotspots(t *testing.T) {
+	h := NewHistogram(time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC), 3*time.Hour, 30*time.Minute)
+	for i, fires := range []int{0, 2, 2, 8, 0, 0} {
+		h.Buckets[i].Fires = fires
+	}
+
+	// empty buckets are left out of the mean
+	if mean := h.FlagHotspots(2); mean != 4 {
+		t.Errorf("mean = %v, want 4", mean)
+	}
+	hotspots := []int{}
+	for i, bucket := range h.Buckets {
+		if bucket.Hotspot {
+			hotspots = append(hotspots, i)
+		}
+	}
+	if !slices.Equal(hotspots, []int{3}) {
+		t.Errorf("hotspots %v, want [3]", hotspots)
+	}
+	if h.Max() != 8 {
+		t.Errorf("Max = %d, want 8", h.Max())
+	}
+
+	// the threshold is inclusive, flagging again clears old flags
+	if h.FlagHotspots(0.5); !h.Buckets[1].Hotspot || h.Buckets[0].Hotspot {
+		t.Errorf("factor 0.5 flags %+v", h.Buckets)
+	}
+
+	empty := NewHistogram(time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC), time.Hour, 30*time.Minute)
+	if mean := empty.FlagHotspots(2); mean != 0 || empty.Buckets[0].Hotspot {
+		t.Errorf("empty histogram: mean %v, hotspot %v", mean, empty.Buckets[0].Hotspot)
+	}
+}
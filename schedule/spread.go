@@ -0,0 +1,286 @@
+package schedule
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mah35h95/break-time/cron"
+)
+
+// Assignment strategies of a Spread
+const (
+	// AssignHash - jobs get the slot their id hashes to, moving on to the next slot with room,
+	// so reruns over the same jobs keep every job in its slot whatever the job list order
+	AssignHash = "hash"
+	// AssignOrder - jobs fill the slots in the order of the job list
+	AssignOrder = "order"
+)
+
+// Spread - spreads the cron schedules of jobs over time slots of a day
+type Spread struct {
+	// Start - time of day of the first slot, minutes after midnight
+	Start int
+	// End - time of day no slot starts at or after, minutes after midnight,
+	// an end before the start wraps over midnight
+	End int
+	// Interval - time between two slots, whole minutes
+	Interval time.Duration
+	// PerSlot - max jobs per slot, 0 spreads the jobs evenly over every slot
+	PerSlot int
+	// Timezone - IANA timezone the slots are in
+	Timezone string
+	// Weekdays - day of week field of the cron expressions, every day when empty
+	Weekdays string
+	// Assign - AssignHash or AssignOrder
+	Assign string
+}
+
+// Slot - a time slot and the jobs assigned to it
+type Slot struct {
+	// Minute - time of day of the slot, minutes after midnight, -1 for a fixed cron
+	Minute int
+	Cron   string
+	Jobs   []string
+}
+
+// Plan - cron expression assigned to every job
+type Plan struct {
+	Timezone string
+	Slots    []Slot
+	// Crons - cron expression of every job id
+	Crons map[string]string
+}
+
+// ParseTimeOfDay - parses HH:MM into minutes after midnight
+func ParseTimeOfDay(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// weekdayNames - names accepted in the day of week field
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ParseWeekdays - normalizes a day of week list like mon-fri,sun to its numeric cron field, 1-5,0
+func ParseWeekdays(s string) (string, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if s == "" || s == "*" {
+		return "", nil
+	}
+
+	day := func(name string) (int, error) {
+		if i := slices.Index(weekdayNames, name); i >= 0 {
+			return i, nil
+		}
+		if i := slices.IndexFunc(weekdayNames, func(n string) bool { return len(name) > 3 && strings.HasPrefix(name, n) }); i >= 0 {
+			return i, nil
+		}
+		n, err := strconv.Atoi(name)
+		if err != nil || n < 0 || n > 7 {
+			return 0, fmt.Errorf("invalid day of week %q", name)
+		}
+		return n % 7, nil
+	}
+
+	fields := []string{}
+	for _, part := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		first, err := day(from)
+		if err != nil {
+			return "", err
+		}
+		if !isRange {
+			fields = append(fields, strconv.Itoa(first))
+			continue
+		}
+
+		last, err := day(to)
+		if err != nil {
+			return "", err
+		}
+		if to == "7" || strings.HasPrefix(to, "sun") {
+			last = 7
+		}
+		if last < first {
+			return "", fmt.Errorf("invalid day of week range %q", part)
+		}
+		fields = append(fields, fmt.Sprintf("%d-%d", first, last))
+	}
+
+	return strings.Join(fields, ","), nil
+}
+
+// Validate - checks the spread is usable
+func (s Spread) Validate() error {
+	errs := []error{}
+	if s.Start < 0 || s.Start >= 24*60 || s.End < 0 || s.End > 24*60 {
+		errs = append(errs, errors.New("start and end must be times of day"))
+	}
+	if s.Interval < time.Minute || s.Interval%time.Minute != 0 {
+		errs = append(errs, fmt.Errorf("interval must be whole minutes, got %s", s.Interval))
+	}
+	if s.PerSlot < 0 {
+		errs = append(errs, fmt.Errorf("jobs per slot can not be negative, got %d", s.PerSlot))
+	}
+	if _, err := cron.LoadTimezone(s.Timezone); err != nil {
+		errs = append(errs, err)
+	}
+	if s.Assign != AssignHash && s.Assign != AssignOrder {
+		errs = append(errs, fmt.Errorf("assign must be %s or %s, got %q", AssignHash, AssignOrder, s.Assign))
+	}
+	if _, err := ParseWeekdays(s.Weekdays); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// slotMinutes - returns the time of day of every slot
+func (s Spread) slotMinutes() []int {
+	length := s.End - s.Start
+	if length <= 0 {
+		length += 24 * 60
+	}
+
+	interval := int(s.Interval / time.Minute)
+	minutes := []int{}
+	for offset := 0; offset < length; offset += interval {
+		minutes = append(minutes, (s.Start+offset)%(24*60))
+	}
+	return minutes
+}
+
+// Plan - assigns a slot to every job, jobs are ids in the order of the job list
+//
+// The same spread and job list always give the same plan.
+func (s Spread) Plan(jobs []string) (*Plan, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	weekdays, _ := ParseWeekdays(s.Weekdays)
+	if weekdays == "" {
+		weekdays = "*"
+	}
+
+	minutes := s.slotMinutes()
+	capacity := s.PerSlot
+	if capacity == 0 {
+		capacity = (len(jobs) + len(minutes) - 1) / len(minutes)
+	}
+	if len(jobs) > capacity*len(minutes) {
+		return nil, fmt.Errorf(
+			"%d jobs do not fit in %d slots of %d jobs, widen the window, shorten the interval or raise the jobs per slot",
+			len(jobs), len(minutes), capacity,
+		)
+	}
+
+	plan := &Plan{
+		Timezone: s.Timezone,
+		Slots:    make([]Slot, len(minutes)),
+		Crons:    map[string]string{},
+	}
+	for i, minute := range minutes {
+		expr := fmt.Sprintf("%d %d * * %s", minute%60, minute/60, weekdays)
+		if err := cron.Validate(expr); err != nil {
+			return nil, err
+		}
+		plan.Slots[i] = Slot{Minute: minute, Cron: expr, Jobs: []string{}}
+	}
+
+	assign := func(job string, slot int) {
+		plan.Slots[slot].Jobs = append(plan.Slots[slot].Jobs, job)
+		plan.Crons[job] = plan.Slots[slot].Cron
+	}
+
+	if s.Assign == AssignOrder {
+		for i, job := range jobs {
+			slot := i / capacity
+			if s.PerSlot == 0 {
+				// even spread, slot job counts differ by one at most
+				slot = i * len(minutes) / max(len(jobs), 1)
+			}
+			assign(job, slot)
+		}
+		return plan, nil
+	}
+
+	hashed := slices.Clone(jobs)
+	slices.SortFunc(hashed, func(a, b string) int {
+		if c := cmp.Compare(hashJob(a), hashJob(b)); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	for _, job := range hashed {
+		slot := int(hashJob(job) % uint64(len(minutes)))
+		for len(plan.Slots[slot].Jobs) >= capacity {
+			slot = (slot + 1) % len(minutes)
+		}
+		assign(job, slot)
+	}
+
+	return plan, nil
+}
+
+// hashJob - stable hash of a job id
+func hashJob(job string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(job))
+	return h.Sum64()
+}
+
+// Fixed - returns a plan giving every job the same cron expression
+func Fixed(expr, timezone string, jobs []string) (*Plan, error) {
+	schedule, err := cron.Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := cron.LoadTimezone(timezone); err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{
+		Timezone: timezone,
+		Slots:    []Slot{{Minute: -1, Cron: schedule.String(), Jobs: slices.Clone(jobs)}},
+		Crons:    map[string]string{},
+	}
+	for _, job := range jobs {
+		plan.Crons[job] = schedule.String()
+	}
+	return plan, nil
+}
+
+// Print - writes one line per slot with its cron expression, job count and next preview fire times
+func (p *Plan) Print(w io.Writer, preview int) {
+	loc, err := cron.LoadTimezone(p.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	now := time.Now().In(loc)
+
+	fmt.Fprintf(w, "Cron plan (%s), %d slot(s):\n", p.Timezone, len(p.Slots))
+	for _, slot := range p.Slots {
+		start := "     "
+		if slot.Minute >= 0 {
+			start = fmt.Sprintf("%02d:%02d", slot.Minute/60, slot.Minute%60)
+		}
+		fmt.Fprintf(w, "  %s  %-16s %4d job(s)", start, slot.Cron, len(slot.Jobs))
+
+		if schedule, err := cron.Parse(slot.Cron); err == nil && preview > 0 {
+			next := []string{}
+			for _, t := range schedule.NextN(now, preview) {
+				next = append(next, t.Format("Mon 2006-01-02 15:04 MST"))
+			}
+			fmt.Fprintf(w, "  next: %s", strings.Join(next, ", "))
+		}
+		fmt.Fprintln(w)
+	}
+}
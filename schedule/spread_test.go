@@ -0,0 +1,260 @@
+package schedule
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSpread() Spread {
+	return Spread{
+		Start:    0,
+		End:      9 * 60,
+		Interval: 30 * time.Minute,
+		PerSlot:  50,
+		Timezone: "America/Chicago",
+		Assign:   AssignHash,
+	}
+}
+
+func testJobs(n int) []string {
+	jobs := make([]string, n)
+	for i := range jobs {
+		jobs[i] = fmt.Sprintf("src.bq.db.schema.table_%03d", i)
+	}
+	return jobs
+}
+
+func TestPlanHashIgnoresJobOrder(t *testing.T) {
+	spread := testSpread()
+	spread.PerSlot = 20
+	jobs := testJobs(300)
+
+	want, err := spread.Plan(jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reversed := slices.Clone(jobs)
+	slices.Reverse(reversed)
+	interleaved := append(slices.Clone(jobs[150:]), jobs[:150]...)
+
+	for name, order := range map[string][]string{"reversed": reversed, "interleaved": interleaved} {
+		got, err := spread.Plan(order)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !maps.Equal(got.Crons, want.Crons) {
+			t.Errorf("%s job order gives other crons", name)
+		}
+	}
+}
+
+func TestPlanHashKeepsSlotsOfRemainingJobs(t *testing.T) {
+	spread := testSpread()
+	jobs := testJobs(100)
+
+	all, err := spread.Plan(jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a rerun over the jobs that failed the first time gives them their first cron
+	// as long as no slot is full
+	some, err := spread.Plan(jobs[40:60])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for job, cron := range some.Crons {
+		if all.Crons[job] != cron {
+			t.Errorf("%s: rerun cron %q, first cron %q", job, cron, all.Crons[job])
+		}
+	}
+}
+
+func TestPlanCapacity(t *testing.T) {
+	for _, assign := range []string{AssignHash, AssignOrder} {
+		spread := testSpread()
+		spread.Assign = assign
+		spread.End = 60 // 2 slots
+		spread.PerSlot = 4
+
+		plan, err := spread.Plan(testJobs(8))
+		if err != nil {
+			t.Fatalf("%s: 8 jobs in 2 slots of 4: %v", assign, err)
+		}
+		for _, slot := range plan.Slots {
+			if len(slot.Jobs) != 4 {
+				t.Errorf("%s: slot %s has %d jobs, want 4", assign, slot.Cron, len(slot.Jobs))
+			}
+		}
+		if len(plan.Crons) != 8 {
+			t.Errorf("%s: %d jobs planned, want 8", assign, len(plan.Crons))
+		}
+
+		_, err = spread.Plan(testJobs(9))
+		if err == nil || !strings.Contains(err.Error(), "do not fit") {
+			t.Errorf("%s: 9 jobs in 2 slots of 4: error = %v, want one saying they do not fit", assign, err)
+		}
+	}
+}
+
+func TestPlanHashRespectsPerSlot(t *testing.T) {
+	spread := testSpread()
+	spread.PerSlot = 7
+	jobs := testJobs(7 * 18) // every slot full
+
+	plan, err := spread.Plan(jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, slot := range plan.Slots {
+		if len(slot.Jobs) != spread.PerSlot {
+			t.Errorf("slot %s has %d jobs, want %d", slot.Cron, len(slot.Jobs), spread.PerSlot)
+		}
+	}
+	if len(plan.Crons) != len(jobs) {
+		t.Errorf("%d jobs planned, want %d", len(plan.Crons), len(jobs))
+	}
+}
+
+func TestPlanEvenSpread(t *testing.T) {
+	for _, assign := range []string{AssignHash, AssignOrder} {
+		spread := testSpread()
+		spread.Assign = assign
+		spread.End = 90 // 3 slots
+		spread.PerSlot = 0
+
+		plan, err := spread.Plan(testJobs(10))
+		if err != nil {
+			t.Fatalf("%s: %v", assign, err)
+		}
+
+		counts := []int{}
+		for _, slot := range plan.Slots {
+			counts = append(counts, len(slot.Jobs))
+		}
+		if slices.Max(counts)-slices.Min(counts) > 1 && assign == AssignOrder {
+			t.Errorf("%s: slot job counts %v differ by more than one", assign, counts)
+		}
+		if slices.Max(counts) > 4 {
+			t.Errorf("%s: slot job counts %v, want at most 4", assign, counts)
+		}
+		if len(plan.Crons) != 10 {
+			t.Errorf("%s: %d jobs planned, want 10", assign, len(plan.Crons))
+		}
+	}
+}
+
+func TestPlanOrderFillsSlotsInJobOrder(t *testing.T) {
+	spread := testSpread()
+	spread.Assign = AssignOrder
+	spread.PerSlot = 2
+
+	plan, err := spread.Plan([]string{"a", "b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"a": "0 0 * * *", "b": "0 0 * * *", "c": "30 0 * * *"}
+	if !maps.Equal(plan.Crons, want) {
+		t.Errorf("Crons = %v, want %v", plan.Crons, want)
+	}
+}
+
+func TestPlanCronExpressions(t *testing.T) {
+	spread := testSpread()
+	spread.Start = 23*60 + 30
+	spread.End = 60
+	spread.Weekdays = "mon-fri"
+	spread.Assign = AssignOrder
+	spread.PerSlot = 1
+
+	plan, err := spread.Plan([]string{"a", "b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"a": "30 23 * * 1-5", "b": "0 0 * * 1-5", "c": "30 0 * * 1-5"}
+	if !maps.Equal(plan.Crons, want) {
+		t.Errorf("Crons = %v, want %v", plan.Crons, want)
+	}
+}
+
+func TestSlotMinutes(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end int
+		interval   time.Duration
+		want       []int
+	}{
+		{"morning", 0, 120, 30 * time.Minute, []int{0, 30, 60, 90}},
+		{"end is exclusive", 60, 180, time.Hour, []int{60, 120}},
+		{"interval not dividing the window", 0, 60, 25 * time.Minute, []int{0, 25, 50}},
+		{"wraps over midnight", 22 * 60, 2 * 60, time.Hour, []int{22 * 60, 23 * 60, 0, 60}},
+		{"wraps to midnight", 23 * 60, 0, 30 * time.Minute, []int{23 * 60, 23*60 + 30}},
+		{"start equal to end is the whole day", 6 * 60, 6 * 60, 6 * time.Hour, []int{6 * 60, 12 * 60, 18 * 60, 0}},
+		{"end at 24:00", 22 * 60, 24 * 60, time.Hour, []int{22 * 60, 23 * 60}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Spread{Start: tt.start, End: tt.end, Interval: tt.interval}
+			if got := s.slotMinutes(); !slices.Equal(got, tt.want) {
+				t.Errorf("slotMinutes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWeekdays(t *testing.T) {
+	tests := map[string]string{
+		"":            "",
+		"*":           "",
+		"mon-fri":     "1-5",
+		"Monday-FRI":  "1-5",
+		"sat,sun":     "6,0",
+		"fri-sun":     "5-7",
+		"1-7":         "1-7",
+		"0,3":         "0,3",
+		" tue , thu ": "2,4",
+	}
+	for in, want := range tests {
+		got, err := ParseWeekdays(in)
+		if err != nil {
+			t.Errorf("ParseWeekdays(%q): %v", in, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParseWeekdays(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	for _, in := range []string{"funday", "8", "fri-mon", "mon-"} {
+		if _, err := ParseWeekdays(in); err == nil {
+			t.Errorf("ParseWeekdays(%q) succeeded, want an error", in)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for name, change := range map[string]func(*Spread){
+		"start out of the day":  func(s *Spread) { s.Start = 24 * 60 },
+		"interval below minute": func(s *Spread) { s.Interval = 30 * time.Second },
+		"interval not whole":    func(s *Spread) { s.Interval = 90 * time.Second },
+		"negative per slot":     func(s *Spread) { s.PerSlot = -1 },
+		"local timezone":        func(s *Spread) { s.Timezone = "Local" },
+		"unknown timezone":      func(s *Spread) { s.Timezone = "Mars/Base" },
+		"unknown assign":        func(s *Spread) { s.Assign = "random" },
+		"invalid weekdays":      func(s *Spread) { s.Weekdays = "someday" },
+	} {
+		s := testSpread()
+		change(&s)
+		if err := s.Validate(); err == nil {
+			t.Errorf("%s: Validate succeeded, want an error", name)
+		}
+	}
+	if err := testSpread().Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mah35h95/break-time/cron"
+	"github.com/mah35h95/break-time/dice"
+	"github.com/mah35h95/break-time/schedule"
+)
+
+// scheduleReportFile - csv of the schedule-report histogram, inside the run dir
+const scheduleReportFile = "schedule-report.csv"
+
+// jobSchedule - schedule fields of a job definition
+type jobSchedule struct {
+	Schedule     string `json:"schedule"`
+	CronTimezone string `json:"cronTimezone"`
+}
+
+// scheduleReport - collects the schedules of the jobs of a schedule-report run
+type scheduleReport struct {
+	window  string
+	bucket  time.Duration
+	hotspot float64
+	csvPath string
+
+	once      sync.Once
+	mu        sync.Mutex
+	histogram *schedule.Histogram
+	// unscheduled - jobs without a schedule, along with the reason
+	unscheduled []string
+}
+
+func setupScheduleReport(fs *flag.FlagSet) (jobFunc, checkFunc) {
+	r := &scheduleReport{}
+	fs.StringVar(&r.window, "window", "24h", "24h: the next UTC day, 7d: the next UTC week starting monday")
+	fs.DurationVar(&r.bucket, "bucket", 30*time.Minute, "width of a histogram bucket")
+	fs.Float64Var(&r.hotspot, "hotspot-factor", 2, "buckets with at least this many times the mean fires are flagged as hotspots")
+	fs.StringVar(&r.csvPath, "csv", "", "also export the histogram to this csv file, it is always written to <run dir>/"+scheduleReportFile)
+
+	return func(ctx context.Context, rc *runContext, n int, id dice.DataSourceID) error {
+		r.init(rc)
+
+		body, err := rc.client.GetJob(ctx, id)
+		if err != nil {
+			return fmt.Errorf("get job: %v", err)
+		}
+
+		job := jobSchedule{}
+		if err := json.Unmarshal(body, &job); err != nil {
+			return fmt.Errorf("decode job: %v", err)
+		}
+
+		return r.add(id, job)
+	}, r.check
+}
+
+// reportWindows - lengths of the --window values
+var reportWindows = map[string]time.Duration{"24h": 24 * time.Hour, "7d": 7 * 24 * time.Hour}
+
+// check - validates --window and --bucket
+func (r *scheduleReport) check() error {
+	window := reportWindows[r.window]
+	if window == 0 {
+		return fmt.Errorf("--window must be 24h or 7d, got %q", r.window)
+	}
+	if r.bucket < time.Minute || window%r.bucket != 0 {
+		return fmt.Errorf("--bucket must be at least 1m and divide --window, got %s", r.bucket)
+	}
+	return nil
+}
+
+// init - registers the report and starts the histogram, once per run
+func (r *scheduleReport) init(rc *runContext) {
+	r.once.Do(func() {
+		start := time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+		if r.window == "7d" {
+			start = start.AddDate(0, 0, (8-int(start.Weekday()))%7)
+		}
+		r.histogram = schedule.NewHistogram(start, reportWindows[r.window], r.bucket)
+
+		rc.atFinish(func() error {
+			return r.report(rc)
+		})
+	})
+}
+
+// add - counts the fires of the job schedule, normalized to UTC
+func (r *scheduleReport) add(id dice.DataSourceID, job jobSchedule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if strings.TrimSpace(job.Schedule) == "" {
+		r.unscheduled = append(r.unscheduled, fmt.Sprintf("%s: no schedule", id))
+		return nil
+	}
+
+	timezone := job.CronTimezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	loc, err := cron.LoadTimezone(timezone)
+	if err != nil {
+		return err
+	}
+	parsed, err := cron.Parse(job.Schedule)
+	if err != nil {
+		return err
+	}
+
+	if r.histogram.Add(id.String(), parsed, loc) == 0 {
+		r.unscheduled = append(r.unscheduled, fmt.Sprintf("%s: %q (%s) does not fire in the window", id, job.Schedule, timezone))
+	}
+	return nil
+}
+
+// report - prints the histogram to rc.out and writes the csv
+func (r *scheduleReport) report(rc *runContext) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h := r.histogram
+	mean := h.FlagHotspots(r.hotspot)
+	highest := h.Max()
+
+	layout := "15:04"
+	if h.Window > 24*time.Hour {
+		layout = "Mon 15:04"
+	}
+
+	fmt.Fprintf(
+		rc.out, "Schedule report, %s window from %s UTC, %s buckets, mean %.1f fires per busy bucket:\n",
+		r.window, h.Start.Format("2006-01-02 15:04"), r.bucket, mean,
+	)
+	empty := 0
+	for _, bucket := range h.Buckets {
+		if bucket.Fires == 0 {
+			empty++
+			continue
+		}
+
+		bar := strings.Repeat("#", max(1, bucket.Fires*40/max(highest, 1)))
+		hotspot := ""
+		if bucket.Hotspot {
+			hotspot = "  <- hotspot"
+		}
+		fmt.Fprintf(rc.out, "  %s  %5d  %s%s\n", bucket.Start.Format(layout), bucket.Fires, bar, hotspot)
+	}
+	fmt.Fprintf(rc.out, "%d of %d buckets have no fires\n", empty, len(h.Buckets))
+
+	sort.Strings(r.unscheduled)
+	printList(rc.progress, "Jobs not in the report:", r.unscheduled)
+
+	paths := []string{filepath.Join(rc.journal.Dir(), scheduleReportFile)}
+	if r.csvPath != "" {
+		paths = append(paths, r.csvPath)
+	}
+	for _, path := range paths {
+		if err := writeHistogramCSV(path, h); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(rc.progress, "Schedule report: %s\n", strings.Join(paths, ", "))
+
+	return nil
+}
+
+// writeHistogramCSV - writes one row per bucket, with the jobs firing in it
+func writeHistogramCSV(path string, h *schedule.Histogram) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create report: %v", err)
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	w.Write([]string{"bucket_start_utc", "bucket_end_utc", "fires", "hotspot", "jobs"})
+	for _, bucket := range h.Buckets {
+		w.Write([]string{
+			bucket.Start.Format(time.RFC3339),
+			bucket.Start.Add(h.Bucket).Format(time.RFC3339),
+			strconv.Itoa(bucket.Fires),
+			strconv.FormatBool(bucket.Hotspot),
+			strings.Join(bucket.Jobs, " "),
+		})
+	}
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("write report %s: %v", path, err)
+	}
+	return file.Close()
+}
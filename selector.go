@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/mah35h95/break-time/dice"
+	"github.com/mah35h95/break-time/utils"
+)
+
+// selectKeys - --select keys choosing where jobs are listed from, comma separated values are listed one by one
+var selectKeys = []string{"source", "technology", "database"}
+
+// selectOps - operators of a --select term, longest first
+var selectOps = []string{"!=", "!~", "=", "~"}
+
+// selectTerm - a single --select term, <key><op><value>
+type selectTerm struct {
+	key   string
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+// selector - jobs chosen by --select terms
+type selector struct {
+	sources, technologies, databases []string
+	// filters - name and job definition field filters
+	filters []selectTerm
+}
+
+// parseSelector - parses the --select terms
+//
+// source, technology and database are required, name filters on <schema>.<table> with a
+// glob (=) or regexp (~), other keys filter on job definition fields, nested with dots.
+func parseSelector(terms []string) (*selector, error) {
+	s := &selector{}
+	errs := []error{}
+	lists := map[string]*[]string{"source": &s.sources, "technology": &s.technologies, "database": &s.databases}
+
+	for _, raw := range terms {
+		term, err := parseSelectTerm(raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("--select %v", err))
+			continue
+		}
+
+		if list, ok := lists[term.key]; ok {
+			if term.op != "=" {
+				errs = append(errs, fmt.Errorf("--select %s: only = is supported for %s", raw, term.key))
+				continue
+			}
+			for _, value := range strings.Split(term.value, ",") {
+				if value = strings.TrimSpace(value); value != "" {
+					*list = append(*list, value)
+				}
+			}
+			continue
+		}
+
+		if term.key == "name" && (term.op == "=" || term.op == "!=") {
+			if _, err := path.Match(term.value, ""); err != nil {
+				errs = append(errs, fmt.Errorf("--select %s: %v", raw, err))
+				continue
+			}
+		}
+		s.filters = append(s.filters, term)
+	}
+
+	for _, key := range selectKeys {
+		if len(*lists[key]) == 0 {
+			errs = append(errs, fmt.Errorf("--select %s=<value> is required", key))
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return s, nil
+}
+
+// parseSelectTerm - splits a term at its first operator
+func parseSelectTerm(raw string) (selectTerm, error) {
+	at, op := -1, ""
+	for _, candidate := range selectOps {
+		if i := strings.Index(raw, candidate); i > 0 && (at < 0 || i < at || (i == at && len(candidate) > len(op))) {
+			at, op = i, candidate
+		}
+	}
+	if at < 0 {
+		return selectTerm{}, fmt.Errorf("%q: expected <key>=<value>, <key>!=<value>, <key>~<regexp> or <key>!~<regexp>", raw)
+	}
+
+	term := selectTerm{key: strings.TrimSpace(raw[:at]), op: op, value: raw[at+len(op):]}
+	if strings.HasSuffix(op, "~") {
+		re, err := regexp.Compile(term.value)
+		if err != nil {
+			return selectTerm{}, fmt.Errorf("%q: %v", raw, err)
+		}
+		term.re = re
+	}
+	return term, nil
+}
+
+// needsDefinition - reports whether the filters read job definition fields
+func (s *selector) needsDefinition() bool {
+	for _, term := range s.filters {
+		if term.key != "name" {
+			return true
+		}
+	}
+	return false
+}
+
+// matches - checks the job against the filters, definition is nil when no field is filtered
+func (s *selector) matches(id dice.DataSourceID, definition map[string]any) bool {
+	for _, term := range s.filters {
+		var value string
+		found := true
+
+		if term.key == "name" {
+			value = id.JobName()
+		} else {
+			value, found = fieldValue(definition, term.key)
+		}
+
+		var match bool
+		switch {
+		case !found:
+			match = false
+		case term.re != nil:
+			match = term.re.MatchString(value)
+		case term.key == "name":
+			match, _ = path.Match(term.value, value)
+		default:
+			match = value == term.value
+		}
+
+		if strings.HasPrefix(term.op, "!") {
+			match = !match
+		}
+		if !match {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldValue - returns a field of the job definition as text, strings unquoted and other values as json
+func fieldValue(definition map[string]any, key string) (string, bool) {
+	var value any = definition
+	for _, part := range strings.Split(key, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return "", false
+		}
+		if value, ok = object[part]; !ok {
+			return "", false
+		}
+	}
+
+	if text, ok := value.(string); ok {
+		return text, true
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// selectJobs - lists the jobs of every source, technology and database and keeps the matching ones
+//
+// Job definitions are only fetched when a field is filtered on, workers at a time. The
+// selected count is reported to progress.
+func selectJobs(ctx context.Context, client *dice.Client, s *selector, workers int, progress io.Writer) ([]utils.JobEntry, error) {
+	listed := []dice.DataSourceID{}
+	for _, source := range s.sources {
+		for _, technology := range s.technologies {
+			for _, database := range s.databases {
+				ids, err := client.ListJobs(ctx, source, technology, database)
+				if err != nil {
+					return nil, fmt.Errorf("list jobs of %s.%s.%s: %v", source, technology, database, err)
+				}
+				listed = append(listed, ids...)
+			}
+		}
+	}
+
+	definitions := make([]map[string]any, len(listed))
+	if s.needsDefinition() {
+		mu := sync.Mutex{}
+		results := utils.RunPool(ctx, listed, workers, func(i int, id dice.DataSourceID) error {
+			body, err := client.GetJob(ctx, id)
+			if err != nil {
+				return err
+			}
+
+			definition := map[string]any{}
+			if err := json.Unmarshal(body, &definition); err != nil {
+				return fmt.Errorf("decode job %s: %v", id, err)
+			}
+
+			mu.Lock()
+			definitions[i] = definition
+			mu.Unlock()
+			return nil
+		})
+
+		errs := []error{}
+		for _, result := range results {
+			if result.Err != nil {
+				errs = append(errs, result.Err)
+			}
+		}
+		if err := errors.Join(errs...); err != nil {
+			return nil, err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	entries := []utils.JobEntry{}
+	for i, id := range listed {
+		if s.matches(id, definitions[i]) {
+			entries = append(entries, utils.JobEntry{ID: id.String(), Source: "--select"})
+		}
+	}
+
+	fmt.Fprintf(progress, "Selected %d of %d listed job(s)\n", len(entries), len(listed))
+	return entries, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mah35h95/break-time/dice"
+)
+
+func TestParseSelectTerm(t *testing.T) {
+	tests := []struct {
+		raw   string
+		key   string
+		op    string
+		value string
+	}{
+		{"source=sap", "source", "=", "sap"},
+		{"name!=s.t*", "name", "!=", "s.t*"},
+		{"name~^s\\.t[0-9]+$", "name", "~", "^s\\.t[0-9]+$"},
+		{"name!~^tmp_", "name", "!~", "^tmp_"},
+		// the first operator splits the term, the rest belongs to the value
+		{"state=a!=b", "state", "=", "a!=b"},
+		{"name~a=b", "name", "~", "a=b"},
+		{"name!=a~b", "name", "!=", "a~b"},
+		{" lake.enabled =true", "lake.enabled", "=", "true"},
+		{"note=", "note", "=", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			term, err := parseSelectTerm(tt.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if term.key != tt.key || term.op != tt.op || term.value != tt.value {
+				t.Errorf("parseSelectTerm = %q %q %q, want %q %q %q", term.key, term.op, term.value, tt.key, tt.op, tt.value)
+			}
+			if (term.re != nil) != strings.HasSuffix(tt.op, "~") {
+				t.Errorf("regexp compiled = %v for %s", term.re != nil, tt.op)
+			}
+		})
+	}
+}
+
+func TestParseSelectTermInvalid(t *testing.T) {
+	for _, raw := range []string{"source", "=sap", "~^sap", "name~[a-", ""} {
+		if _, err := parseSelectTerm(raw); err == nil {
+			t.Errorf("parseSelectTerm(%q) succeeded", raw)
+		}
+	}
+}
+
+func TestParseSelector(t *testing.T) {
+	s, err := parseSelector([]string{"source=sap, oracle", "technology=bq", "database=db1", "database=db2", "name=s.*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(s.sources, ",") != "sap,oracle" || strings.Join(s.databases, ",") != "db1,db2" || len(s.filters) != 1 {
+		t.Errorf("parseSelector = %+v", s)
+	}
+	if s.needsDefinition() {
+		t.Error("name filters need no job definition")
+	}
+
+	_, err = parseSelector([]string{"source~sap", "name=[", "technology=bq"})
+	if err == nil {
+		t.Fatal("parseSelector succeeded")
+	}
+	for _, want := range []string{"only = is supported for source", "syntax error in pattern", "database=<value> is required"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	definition := map[string]any{}
+	err := json.Unmarshal([]byte(`{
+		"state": "ACTIVE",
+		"chunks": 5,
+		"ratio": 1.5,
+		"lake": {"enabled": true, "projects": ["p1", "p2"]},
+		"note": null
+	}`), &definition)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := dice.ParseDataSourceID("sap.bq.db.sales.orders_2024")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		term string
+		want bool
+	}{
+		{"name=sales.orders_*", true},
+		{"name=orders_*", false},
+		{"name!=sales.orders_*", false},
+		{"name~_[0-9]{4}$", true},
+		{"name!~^sales\\.", false},
+		{"state=ACTIVE", true},
+		{"state!=ACTIVE", false},
+		{"state~^ACT", true},
+		// values other than strings are compared as json
+		{"chunks=5", true},
+		{"chunks=5.0", false},
+		{"chunks~^[0-9]$", true},
+		{"ratio=1.5", true},
+		{"lake.enabled=true", true},
+		{"lake.projects=[\"p1\",\"p2\"]", true},
+		{"lake={\"enabled\":true,\"projects\":[\"p1\",\"p2\"]}", true},
+		{"note=null", true},
+		// missing fields never match, so != and !~ keep the job
+		{"missing=", false},
+		{"missing!=x", true},
+		{"lake.enabled.deeper=true", false},
+		{"state.deeper!~.", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.term, func(t *testing.T) {
+			term, err := parseSelectTerm(tt.term)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := &selector{filters: []selectTerm{term}}
+			if got := s.matches(id, definition); got != tt.want {
+				t.Errorf("matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// every filter has to match
+	s := &selector{}
+	for _, raw := range []string{"name=sales.*", "state=ACTIVE", "chunks=6"} {
+		term, _ := parseSelectTerm(raw)
+		s.filters = append(s.filters, term)
+	}
+	if s.matches(id, definition) {
+		t.Error("matches with a failing filter")
+	}
+	if !s.needsDefinition() {
+		t.Error("field filters need the job definition")
+	}
+}
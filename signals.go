@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// handleSignals - returns a dispatch context done on the first SIGINT/SIGTERM and a job
+// context done once the in-flight jobs had shutdownTimeout to finish or on a second signal
+//
+// The signals received are reported to progress.
+func handleSignals(shutdownTimeout time.Duration, progress io.Writer) (dispatchCtx, jobCtx context.Context, stop func()) {
+	dispatchCtx, cancelDispatch := context.WithCancel(context.Background())
+	jobCtx, cancelJobs := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			fmt.Fprintf(progress, "\nReceived %s, no new jobs will be started. Waiting up to %s for running jobs, signal again to abort them...\n", sig, shutdownTimeout)
+			cancelDispatch()
+		case <-done:
+			return
+		}
+
+		timer := time.NewTimer(shutdownTimeout)
+		defer timer.Stop()
+
+		select {
+		case sig := <-signals:
+			fmt.Fprintf(progress, "\nReceived %s again, aborting running jobs...\n", sig)
+		case <-timer.C:
+			fmt.Fprintf(progress, "\nRunning jobs did not finish within %s, aborting them...\n", shutdownTimeout)
+		case <-done:
+		}
+		cancelJobs()
+	}()
+
+	once := sync.Once{}
+	stop = func() {
+		once.Do(func() {
+			signal.Stop(signals)
+			close(done)
+			cancelDispatch()
+			cancelJobs()
+		})
+	}
+
+	return dispatchCtx, jobCtx, stop
+}
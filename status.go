@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/mah35h95/break-time/dice"
+)
+
+// statusReport - collects the statuses of the jobs of a status run
+type statusReport struct {
+	output string
+	out    string
+
+	once     sync.Once
+	mu       sync.Mutex
+	statuses map[int]dice.JobStatus
+}
+
+func setupStatus(fs *flag.FlagSet) (jobFunc, checkFunc) {
+	r := &statusReport{statuses: map[int]dice.JobStatus{}}
+	fs.StringVar(&r.output, "output", "table", "table or json")
+	fs.StringVar(&r.out, "out", "", "write the statuses to this file instead of stdout")
+	check := func() error {
+		if r.output != "table" && r.output != "json" {
+			return fmt.Errorf("--output must be table or json, got %q", r.output)
+		}
+		return nil
+	}
+
+	return func(ctx context.Context, rc *runContext, n int, id dice.DataSourceID) error {
+		r.once.Do(func() {
+			rc.atFinish(func() error {
+				return r.print(rc.out)
+			})
+		})
+
+		status, err := rc.client.GetJobStatus(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		r.mu.Lock()
+		r.statuses[n] = status
+		r.mu.Unlock()
+		return nil
+	}, check
+}
+
+// print - writes the statuses in job list order to --out, or stdout
+func (r *statusReport) print(stdout io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	positions := []int{}
+	for n := range r.statuses {
+		positions = append(positions, n)
+	}
+	sort.Ints(positions)
+
+	statuses := make([]dice.JobStatus, len(positions))
+	for i, n := range positions {
+		statuses[i] = r.statuses[n]
+	}
+
+	w := stdout
+	if r.out != "" {
+		file, err := os.Create(r.out)
+		if err != nil {
+			return fmt.Errorf("create %s: %v", r.out, err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if r.output == "json" {
+		data, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			return fmt.Errorf("json.Marshal: %v", err)
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "JOB\tSTATE\tLOCKED\tSCHEDULE\tTIMEZONE\tNEW LAKE\tTARGET PROJECTS")
+	for _, status := range statuses {
+		fmt.Fprintf(
+			tw, "%s\t%s\t%t\t%s\t%s\t%t\t%s\n",
+			status.Job, orDash(status.State), status.Locked, orDash(status.Schedule),
+			orDash(status.CronTimezone), status.NewLakeJob, orDash(strings.Join(status.TargetProjectIds, ",")),
+		)
+	}
+	return tw.Flush()
+}
+
+// orDash - returns "-" for empty table cells
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,196 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Limiter - token bucket rate limiter, safe for concurrent use
+//
+// A nil Limiter or one with a rate <= 0 does not limit.
+type Limiter struct {
+	name  string
+	rate  float64
+	burst float64
+	log   io.Writer
+
+	mu          sync.Mutex
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+// NewLimiter - returns a limiter allowing rps requests per second with bursts of up to burst requests
+//
+// Pauses asked by the server are reported to log, os.Stdout when nil.
+func NewLimiter(name string, rps float64, burst int, log io.Writer) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	if log == nil {
+		log = os.Stdout
+	}
+
+	return &Limiter{
+		name:   name,
+		rate:   rps,
+		burst:  float64(burst),
+		log:    log,
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait - blocks until a request may be sent or ctx is done
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	for {
+		wait := l.reserve()
+		if wait <= 0 {
+			return nil
+		}
+
+		if err := Sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// reserve - takes a token, or returns how long to wait before trying again
+func (l *Limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+	if l.rate <= 0 {
+		return 0
+	}
+
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// PauseFor - holds every request of the limiter for d, eg. when the server sent a Retry-After
+func (l *Limiter) PauseFor(d time.Duration) {
+	if l == nil || d <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until := time.Now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+		fmt.Fprintf(l.log, "%s: server asked to back off, pausing requests for %s\n", l.name, d.Round(time.Millisecond))
+	}
+}
+
+// RateLimited - wraps base so every request waits for the limiter
+//
+// 429 and 503 responses with a Retry-After header pause the limiter for that long. They are
+// returned as they are, whether to send the request again is up to Retrying wrapping it.
+func RateLimited(base http.RoundTripper, limiter *Limiter) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &rateLimitedTransport{base: base, limiter: limiter}
+}
+
+type rateLimitedTransport struct {
+	base    http.RoundTripper
+	limiter *Limiter
+}
+
+// RoundTrip - implements http.RoundTripper
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	response, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusTooManyRequests || response.StatusCode == http.StatusServiceUnavailable {
+		if retryAfter, ok := RetryAfter(response); ok {
+			t.limiter.PauseFor(retryAfter)
+		}
+	}
+	return response, nil
+}
+
+// RetryAfter - parses the Retry-After header of a response, in seconds or as an http date
+func RetryAfter(response *http.Response) (time.Duration, bool) {
+	value := response.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date), true
+	}
+
+	return 0, false
+}
+
+// rewind - returns a copy of req with a fresh body so it can be sent again
+func rewind(req *http.Request) (*http.Request, error) {
+	next := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return next, nil
+	}
+	if req.GetBody == nil {
+		return nil, fmt.Errorf("request body can not be replayed")
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	next.Body = body
+
+	return next, nil
+}
+
+// Sleep - waits for d or until ctx is done
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
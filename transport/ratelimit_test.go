@@ -0,0 +1,90 @@
+package transport
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimitedDoesNotReplay(t *testing.T) {
+	sends := atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sends.Add(1)
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	limiter := NewLimiter("test", 0, 1, io.Discard)
+	client := &http.Client{Transport: RateLimited(nil, limiter)}
+
+	response, err := client.Post(server.URL+"/load", "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("status %d, want the 429", response.StatusCode)
+	}
+	if got := sends.Load(); got != 1 {
+		t.Errorf("request sent %d times, want once", got)
+	}
+	if wait := limiter.reserve(); wait <= time.Second {
+		t.Errorf("limiter paused for %s, want the 2s of Retry-After", wait)
+	}
+}
+
+func TestRateLimitedUnsafePostSentOnceWhenRetrying(t *testing.T) {
+	sends := atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sends.Add(1)
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	client := &http.Client{Transport: Retrying(RateLimited(nil, NewLimiter("test", 0, 1, io.Discard)), policy, io.Discard)}
+
+	response, err := client.Post(server.URL+"/load", "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if got := sends.Load(); got != 1 {
+		t.Errorf("unsafe POST sent %d times, want once", got)
+	}
+
+	sends.Store(0)
+	response, err = client.Get(server.URL + "/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if got := sends.Load(); got != int32(policy.MaxAttempts) {
+		t.Errorf("GET sent %d times, want MaxAttempts %d", got, policy.MaxAttempts)
+	}
+}
+
+func TestLimiterRate(t *testing.T) {
+	limiter := NewLimiter("test", 10, 2, io.Discard)
+	for i := range 2 {
+		if wait := limiter.reserve(); wait != 0 {
+			t.Fatalf("request %d of the burst waits %s", i+1, wait)
+		}
+	}
+	if wait := limiter.reserve(); wait <= 0 || wait > 100*time.Millisecond {
+		t.Errorf("request after the burst waits %s, want up to 100ms", wait)
+	}
+
+	var unlimited *Limiter
+	if err := unlimited.Wait(context.Background()); err != nil {
+		t.Errorf("nil limiter: %v", err)
+	}
+}
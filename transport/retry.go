@@ -0,0 +1,144 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"time"
+)
+
+// RetryPolicy - how often and how long to wait between attempts of a request
+type RetryPolicy struct {
+	// MaxAttempts - total attempts including the first one, 1 disables retries
+	MaxAttempts int
+	// BaseDelay - delay before the second attempt, doubled for every following one
+	BaseDelay time.Duration
+	// MaxDelay - caps the delay between two attempts, 0 for no cap
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy - used when no policy is configured
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
+
+// Backoff - returns the jittered delay before the attempt following the given one
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || delay < p.MaxDelay) && delay < math.MaxInt64/2; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	// equal jitter, somewhere between half and the full delay
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+type retryKey struct{}
+
+// retryOptions - per request overrides carried by the request context
+type retryOptions struct {
+	policy RetryPolicy
+	// safe - the request may be retried even though its method is not idempotent
+	safe bool
+}
+
+// WithRetry - overrides the retry policy for requests made with ctx, safe marks
+// non idempotent requests (eg. POST commands) as retryable
+func WithRetry(ctx context.Context, policy RetryPolicy, safe bool) context.Context {
+	return context.WithValue(ctx, retryKey{}, retryOptions{policy: policy, safe: safe})
+}
+
+// Retrying - wraps base so idempotent and explicitly safe requests are retried on
+// connection errors, 429 and 5xx responses with exponential backoff
+//
+// Every retry is reported to log, os.Stdout when nil.
+func Retrying(base http.RoundTripper, policy RetryPolicy, log io.Writer) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if log == nil {
+		log = os.Stdout
+	}
+	return &retryTransport{base: base, policy: policy, log: log}
+}
+
+type retryTransport struct {
+	base   http.RoundTripper
+	policy RetryPolicy
+	log    io.Writer
+}
+
+// RoundTrip - implements http.RoundTripper
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	opts, ok := req.Context().Value(retryKey{}).(retryOptions)
+	if !ok {
+		opts = retryOptions{policy: t.policy}
+	}
+	retryable := opts.safe || isIdempotent(req.Method)
+
+	for attempt := 1; ; attempt++ {
+		response, err := t.base.RoundTrip(req)
+
+		if !retryable || attempt >= opts.policy.MaxAttempts || req.Context().Err() != nil || !shouldRetry(response, err) {
+			return response, err
+		}
+
+		next, rewindErr := rewind(req)
+		if rewindErr != nil {
+			return response, err
+		}
+
+		delay := opts.policy.Backoff(attempt)
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = response.Status
+			if retryAfter, ok := RetryAfter(response); ok && retryAfter > delay {
+				delay = retryAfter
+			}
+			io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+			response.Body.Close()
+		}
+
+		fmt.Fprintf(
+			t.log, "%s %s failed (attempt %d/%d): %s, retrying in %s\n",
+			req.Method, req.URL.Redacted(), attempt, opts.policy.MaxAttempts, reason, delay.Round(time.Millisecond),
+		)
+
+		if err := Sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+		req = next
+	}
+}
+
+// shouldRetry - connection errors, 429 and 5xx responses are transient
+func shouldRetry(response *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500
+}
+
+// isIdempotent - methods that can be sent again without changing the outcome
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package transport
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{"first attempt", RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Minute}, 1, time.Second},
+		{"doubled", RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Minute}, 4, 8 * time.Second},
+		{"capped", RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}, 4, 5 * time.Second},
+		{"no cap", RetryPolicy{BaseDelay: time.Second}, 4, 8 * time.Second},
+		{"no base delay", RetryPolicy{MaxDelay: time.Minute}, 3, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 20 {
+				got := tt.policy.Backoff(tt.attempt)
+				if got < tt.want/2 || got > tt.want {
+					t.Fatalf("Backoff(%d) = %s, want between %s and %s", tt.attempt, got, tt.want/2, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBackoffNoCapDoesNotOverflow(t *testing.T) {
+	if got := (RetryPolicy{BaseDelay: time.Second}).Backoff(200); got <= 0 {
+		t.Fatalf("Backoff(200) = %s, want a positive delay", got)
+	}
+}
+
+// statusServer - answers with the statuses in order, the last one for every further request
+func statusServer(t *testing.T, statuses []int, header http.Header) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	sends := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(sends.Add(1))
+		body, _ := io.ReadAll(r.Body)
+		if r.Method == http.MethodPost && string(body) != `{"a":1}` {
+			t.Errorf("attempt %d sent body %q", n, body)
+		}
+		for key, values := range header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(statuses[min(n, len(statuses))-1])
+	}))
+	t.Cleanup(server.Close)
+	return server, sends
+}
+
+func TestRetryingRoundTrip(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+	tests := []struct {
+		name      string
+		method    string
+		safe      bool
+		statuses  []int
+		wantSends int32
+		want      int
+	}{
+		{"5xx retried", http.MethodGet, false, []int{502, 503, 200}, 3, 200},
+		{"MaxAttempts respected", http.MethodGet, false, []int{500}, 3, 500},
+		{"4xx not retried", http.MethodGet, false, []int{404, 200}, 1, 404},
+		{"429 retried", http.MethodGet, false, []int{429, 200}, 2, 200},
+		{"unsafe POST not retried", http.MethodPost, false, []int{503, 200}, 1, 503},
+		{"unsafe POST 429 not retried", http.MethodPost, false, []int{429, 200}, 1, 429},
+		{"safe POST retried with its body", http.MethodPost, true, []int{503, 500, 200}, 3, 200},
+		{"idempotent DELETE retried", http.MethodDelete, false, []int{500, 200}, 2, 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, sends := statusServer(t, tt.statuses, nil)
+
+			ctx := context.Background()
+			if tt.safe {
+				ctx = WithRetry(ctx, policy, true)
+			}
+			var body io.Reader
+			if tt.method == http.MethodPost {
+				body = strings.NewReader(`{"a":1}`)
+			}
+			req, err := http.NewRequestWithContext(ctx, tt.method, server.URL, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			response, err := (&http.Client{Transport: Retrying(nil, policy, io.Discard)}).Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response.Body.Close()
+
+			if response.StatusCode != tt.want {
+				t.Errorf("status %d, want %d", response.StatusCode, tt.want)
+			}
+			if got := sends.Load(); got != tt.wantSends {
+				t.Errorf("sent %d times, want %d", got, tt.wantSends)
+			}
+		})
+	}
+}
+
+func TestRetryingHonoursRetryAfter(t *testing.T) {
+	server, sends := statusServer(t, []int{429, 200}, http.Header{"Retry-After": {"1"}})
+	policy := RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	start := time.Now()
+	response, err := (&http.Client{Transport: Retrying(nil, policy, io.Discard)}).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusOK || sends.Load() != 2 {
+		t.Fatalf("status %d after %d sends, want 200 after 2", response.StatusCode, sends.Load())
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want the 1s of Retry-After over the 1ms backoff", elapsed)
+	}
+}
+
+func TestRetryingPolicyOverride(t *testing.T) {
+	server, sends := statusServer(t, []int{500}, nil)
+	ctx := WithRetry(context.Background(), RetryPolicy{MaxAttempts: 1}, false)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := (&http.Client{Transport: Retrying(nil, DefaultRetryPolicy, io.Discard)}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	if got := sends.Load(); got != 1 {
+		t.Errorf("sent %d times with a MaxAttempts 1 override, want once", got)
+	}
+}
+
+func TestRetryingConnectionErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	policy := RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}
+	if _, err := (&http.Client{Transport: Retrying(nil, policy, io.Discard)}).Get(url); err == nil {
+		t.Error("GET of a closed server succeeded")
+	}
+}
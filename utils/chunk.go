@@ -1,8 +1,8 @@
 package utils
 
 // ChunkJobs - chunks jobs to a set chunk size
-func ChunkJobs(jobIDs []string, chunkSize int) [][]string {
-	chunkJobIDs := [][]string{}
+func ChunkJobs[T any](jobIDs []T, chunkSize int) [][]T {
+	chunkJobIDs := [][]T{}
 
 	for i := 0; i < len(jobIDs); i += chunkSize {
 		if i+chunkSize <= len(jobIDs) {
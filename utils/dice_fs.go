@@ -1,13 +1,18 @@
 package utils
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"math"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
+
+	"github.com/mah35h95/break-time/auth"
+	"github.com/mah35h95/break-time/dice"
 )
 
 // GcsListResponce - GCS responce struct
@@ -17,23 +22,54 @@ type GcsListResponce struct {
 	Prefixes      []string `json:"prefixes"`
 }
 
-func GetTransactionsDirs(bucketName, dataSourceId, bearer string) []string {
+// DiceFS - lists the folders jobs keep in the dice fs GCS bucket
+type DiceFS struct {
+	// Bucket - dice fs bucket, <project>-dice-fs
+	Bucket string
+	// Tokens - source of the access token sent to GCS
+	Tokens auth.TokenSource
+	// HTTPClient - client used to call GCS, http.DefaultClient when nil
+	HTTPClient *http.Client
+	// Log - listing progress is reported to it, os.Stdout when nil
+	Log io.Writer
+}
+
+// GetTransactionsDirs - returns the transactions folders of the job, leaving out the latest 5
+func (fs *DiceFS) GetTransactionsDirs(ctx context.Context, id dice.DataSourceID) ([]string, error) {
+	return fs.listDirs(ctx, id, "transactions", 5)
+}
+
+// GetCurrentDirs - returns the current folders of the job, leaving out the latest 2
+func (fs *DiceFS) GetCurrentDirs(ctx context.Context, id dice.DataSourceID) ([]string, error) {
+	return fs.listDirs(ctx, id, "current", 2)
+}
+
+// GetDeltaDirs - returns every delta folder of the job
+func (fs *DiceFS) GetDeltaDirs(ctx context.Context, id dice.DataSourceID) ([]string, error) {
+	return fs.listDirs(ctx, id, "delta", 0)
+}
+
+// listDirs - pages through the folders under <job prefix>/<folder>/, leaving out the latest keep
+func (fs *DiceFS) listDirs(ctx context.Context, id dice.DataSourceID, folder string, keep int) ([]string, error) {
 	allDirs := []string{}
 
-	parts := strings.Split(dataSourceId, ".")
-	if len(parts) != 5 {
-		return allDirs
+	log := fs.Log
+	if log == nil {
+		log = os.Stdout
 	}
 
-	prefix := fmt.Sprintf("%s/transactions/", strings.ReplaceAll(dataSourceId, ".", "/"))
+	prefix := id.StoragePrefix() + folder + "/"
 	pageToken := ""
 
 	count := 1
 	for {
-		dirs, nextPageToken := getDirs(pageToken, prefix, bearer, bucketName)
+		dirs, nextPageToken, err := fs.getDirs(ctx, pageToken, prefix)
+		if err != nil {
+			return nil, fmt.Errorf("list %s: %v", prefix, err)
+		}
 		allDirs = append(allDirs, dirs...)
 
-		fmt.Printf("%s: Fetched files %d times\n", dataSourceId, count)
+		fmt.Fprintf(log, "%s: Fetched files %d times\n", id, count)
 		count++
 
 		pageToken = nextPageToken
@@ -42,18 +78,14 @@ func GetTransactionsDirs(bucketName, dataSourceId, bearer string) []string {
 		}
 	}
 
-	n := 5 // Number of elements to remove from the end
-
-	if len(allDirs) >= n {
-		allDirs = allDirs[:len(allDirs)-n]
-	} else {
-		allDirs = []string{}
+	if len(allDirs) < keep {
+		return []string{}, nil
 	}
 
-	return allDirs
+	return allDirs[:len(allDirs)-keep], nil
 }
 
-func getDirs(pageToken, prefix, bearer, bucketName string) ([]string, string) {
+func (fs *DiceFS) getDirs(ctx context.Context, pageToken, prefix string) ([]string, string, error) {
 	queryParams := url.Values{
 		"versions":   []string{"true"},
 		"delimiter":  []string{"/"},
@@ -64,119 +96,49 @@ func getDirs(pageToken, prefix, bearer, bucketName string) ([]string, string) {
 
 	path := fmt.Sprintf(
 		"https://storage.googleapis.com/storage/v1/b/%s/o?%s",
-		bucketName,
+		fs.Bucket,
 		queryParams.Encode(),
 	)
 
-	req, err := http.NewRequest(http.MethodGet, path, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("http.NewRequest: %v", err)
+	}
+
+	token, err := fs.Tokens.Token()
 	if err != nil {
-		fmt.Printf("New Request Create: %+v\n", err)
-		return []string{}, ""
+		return nil, "", fmt.Errorf("access token: %v", err)
 	}
 
 	req.Header = http.Header{
-		"Authorization": {bearer},
+		"Authorization": {"Bearer " + token},
 		"Content-Type":  {"application/json"},
 	}
 
-	// Send req using http Client
-	client := &http.Client{}
+	client := fs.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
 	res, err := client.Do(req)
 	if err != nil {
-		fmt.Printf("Http Do: %+v\n", err)
-		return []string{}, ""
-	}
-	if res.StatusCode == 403 {
-		fmt.Printf("Un-Authorized: %+v\n", err)
-		return []string{}, ""
+		return nil, "", fmt.Errorf("client.Do: %v", err)
 	}
 	defer res.Body.Close()
 
 	resBody, err := io.ReadAll(res.Body)
 	if err != nil {
-		fmt.Printf("Read Body: %+v\n", err)
-		return []string{}, ""
+		return nil, "", fmt.Errorf("read body: %v", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("status %d: %s", res.StatusCode, strings.TrimSpace(string(resBody)))
 	}
 
 	gcsListRes := GcsListResponce{}
 	err = json.Unmarshal(resBody, &gcsListRes)
 	if err != nil {
-		fmt.Printf("JSON Unmarshaling: %+v\n", err)
-		fmt.Printf("%s\n", string(resBody))
-		return []string{}, ""
-	}
-
-	return gcsListRes.Prefixes, gcsListRes.NextPageToken
-}
-
-func GetCurrentDirs(bucketName, dataSourceId, bearer string) []string {
-	allDirs := []string{}
-
-	parts := strings.Split(dataSourceId, ".")
-	if len(parts) != 5 {
-		return allDirs
+		return nil, "", fmt.Errorf("json.Unmarshal: %v", err)
 	}
 
-	prefix := fmt.Sprintf("%s/current/", strings.ReplaceAll(dataSourceId, ".", "/"))
-	pageToken := ""
-
-	count := 1
-	for {
-		dirs, nextPageToken := getDirs(pageToken, prefix, bearer, bucketName)
-		allDirs = append(allDirs, dirs...)
-
-		fmt.Printf("%s: Fetched files %d times\n", dataSourceId, count)
-		count++
-
-		pageToken = nextPageToken
-		if pageToken == "" {
-			break
-		}
-	}
-
-	n := 2 // Number of elements to remove from the end
-
-	if len(allDirs) >= n {
-		allDirs = allDirs[:len(allDirs)-n]
-	} else {
-		allDirs = []string{}
-	}
-
-	return allDirs
-}
-
-func GetDeltaDirs(bucketName, dataSourceId, bearer string) []string {
-	allDirs := []string{}
-
-	parts := strings.Split(dataSourceId, ".")
-	if len(parts) != 5 {
-		return allDirs
-	}
-
-	prefix := fmt.Sprintf("%s/delta/", strings.ReplaceAll(dataSourceId, ".", "/"))
-	pageToken := ""
-
-	count := 1
-	for {
-		dirs, nextPageToken := getDirs(pageToken, prefix, bearer, bucketName)
-		allDirs = append(allDirs, dirs...)
-
-		fmt.Printf("%s: Fetched files %d times\n", dataSourceId, count)
-		count++
-
-		pageToken = nextPageToken
-		if pageToken == "" {
-			break
-		}
-	}
-
-	// n := 2 // Number of elements to remove from the end
-
-	// if len(allDirs) >= n {
-	// 	allDirs = allDirs[:len(allDirs)-n]
-	// } else {
-	// 	allDirs = []string{}
-	// }
-
-	return allDirs
+	return gcsListRes.Prefixes, gcsListRes.NextPageToken, nil
 }
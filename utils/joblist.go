@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// JobEntry - a job id read from a job list along with where it was read from
+type JobEntry struct {
+	ID string
+	// Source - <name>:<line> of the entry, <name>[<index>] for json lists
+	Source string
+}
+
+// jobIDColumns - csv header names holding the job id, compared case insensitively
+var jobIDColumns = []string{"datasourceid", "jobid", "job", "id"}
+
+// ReadJobList - reads a job list as a json array, csv or one id per line
+//
+// Blank lines and lines starting with '#' are skipped. The format is picked from
+// the extension of name, falling back to the content when it does not tell.
+func ReadJobList(name string, r io.Reader) ([]JobEntry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %v", name, err)
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	switch ext := strings.ToLower(filepath.Ext(name)); {
+	case ext == ".json" || bytes.HasPrefix(trimmed, []byte("[")):
+		return readJSONJobList(name, trimmed)
+	case ext == ".csv":
+		return readCSVJobList(name, data)
+	default:
+		return readLineJobList(name, data), nil
+	}
+}
+
+// readLineJobList - one id per line
+func readLineJobList(name string, data []byte) []JobEntry {
+	entries := []JobEntry{}
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, JobEntry{ID: line, Source: fmt.Sprintf("%s:%d", name, i+1)})
+	}
+
+	return entries
+}
+
+// readCSVJobList - the id column when the header names one, the first column otherwise
+func readCSVJobList(name string, data []byte) ([]JobEntry, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	entries := []JobEntry{}
+	column := 0
+
+	for record := 1; ; record++ {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %v", name, err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		if record == 1 {
+			if header := csvIDColumn(fields); header >= 0 {
+				column = header
+				continue
+			}
+		}
+
+		if column >= len(fields) {
+			continue
+		}
+
+		id := strings.TrimSpace(fields[column])
+		if id == "" {
+			continue
+		}
+		entries = append(entries, JobEntry{ID: id, Source: fmt.Sprintf("%s:%d", name, line)})
+	}
+
+	return entries, nil
+}
+
+// csvIDColumn - index of the job id column when fields is a header, -1 otherwise
+func csvIDColumn(fields []string) int {
+	for _, want := range jobIDColumns {
+		for i, field := range fields {
+			if strings.EqualFold(strings.TrimSpace(field), want) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// readJSONJobList - an array of ids or of objects with a dataSourceId field
+func readJSONJobList(name string, data []byte) ([]JobEntry, error) {
+	items := []json.RawMessage{}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("decode %s: expected a json array: %v", name, err)
+	}
+
+	entries := []JobEntry{}
+	for i, item := range items {
+		source := fmt.Sprintf("%s[%d]", name, i)
+
+		id := ""
+		if err := json.Unmarshal(item, &id); err != nil {
+			object := struct {
+				DataSourceID string `json:"dataSourceId"`
+			}{}
+			if err := json.Unmarshal(item, &object); err != nil || object.DataSourceID == "" {
+				return nil, fmt.Errorf("%s: expected a string or an object with a dataSourceId", source)
+			}
+			id = object.DataSourceID
+		}
+
+		if id = strings.TrimSpace(id); id != "" {
+			entries = append(entries, JobEntry{ID: id, Source: source})
+		}
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadJobList(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []JobEntry
+	}{
+		{
+			name:    "one id per line with comments and blank lines",
+			file:    "jobs.txt",
+			content: "# pause list\na.b.c.d.e\n\n  a.b.c.d.f  \r\n# a.b.c.d.g\n",
+			want:    []JobEntry{{"a.b.c.d.e", "jobs.txt:2"}, {"a.b.c.d.f", "jobs.txt:4"}},
+		},
+		{
+			name:    "duplicates are kept for the caller to report",
+			file:    "jobs",
+			content: "a.b.c.d.e\na.b.c.d.e\n",
+			want:    []JobEntry{{"a.b.c.d.e", "jobs:1"}, {"a.b.c.d.e", "jobs:2"}},
+		},
+		{
+			name:    "csv with a dataSourceId header column",
+			file:    "jobs.csv",
+			content: "state,DataSourceId,cron\nACTIVE,a.b.c.d.e,0 6 * * *\n# skipped\nPAUSED, a.b.c.d.f,\n",
+			want:    []JobEntry{{"a.b.c.d.e", "jobs.csv:2"}, {"a.b.c.d.f", "jobs.csv:4"}},
+		},
+		{
+			name:    "csv header names compared case insensitively",
+			file:    "jobs.csv",
+			content: "JOB\na.b.c.d.e\n",
+			want:    []JobEntry{{"a.b.c.d.e", "jobs.csv:2"}},
+		},
+		{
+			name:    "csv without a header uses the first column",
+			file:    "jobs.csv",
+			content: "a.b.c.d.e,ACTIVE\n\na.b.c.d.f\n,ACTIVE\n",
+			want:    []JobEntry{{"a.b.c.d.e", "jobs.csv:1"}, {"a.b.c.d.f", "jobs.csv:3"}},
+		},
+		{
+			name:    "csv rows without the id column are skipped",
+			file:    "jobs.csv",
+			content: "state,id\nACTIVE\nACTIVE,a.b.c.d.e\n",
+			want:    []JobEntry{{"a.b.c.d.e", "jobs.csv:3"}},
+		},
+		{
+			name:    "json array of ids",
+			file:    "jobs.json",
+			content: `["a.b.c.d.e", " a.b.c.d.f ", ""]`,
+			want:    []JobEntry{{"a.b.c.d.e", "jobs.json[0]"}, {"a.b.c.d.f", "jobs.json[1]"}},
+		},
+		{
+			name:    "json array of objects",
+			file:    "jobs.json",
+			content: `[{"dataSourceId":"a.b.c.d.e","state":"ACTIVE"},"a.b.c.d.f"]`,
+			want:    []JobEntry{{"a.b.c.d.e", "jobs.json[0]"}, {"a.b.c.d.f", "jobs.json[1]"}},
+		},
+		{
+			name:    "json detected from the content",
+			file:    "stdin",
+			content: "\n  [\"a.b.c.d.e\"]\n",
+			want:    []JobEntry{{"a.b.c.d.e", "stdin[0]"}},
+		},
+		{
+			name:    "empty file",
+			file:    "jobs.txt",
+			content: "",
+			want:    []JobEntry{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadJobList(tt.file, strings.NewReader(tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadJobList = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadJobListInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"json object", "jobs.json", `{"dataSourceId":"a.b.c.d.e"}`},
+		{"json truncated", "jobs.json", `["a.b.c.d.e"`},
+		{"json object without dataSourceId", "jobs.json", `[{"id":"a.b.c.d.e"}]`},
+		{"json number", "jobs.json", `[12]`},
+		{"csv with a broken quote", "jobs.csv", "id\n\"a.b.c.d.e\n"},
+	}
+
+	for _, tt := range tests {
+		if _, err := ReadJobList(tt.file, strings.NewReader(tt.content)); err == nil {
+			t.Errorf("%s: ReadJobList succeeded, want an error", tt.name)
+		}
+	}
+}
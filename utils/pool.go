@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"context"
+	"sync"
+)
+
+// Result - outcome of a single item run by RunPool
+type Result[T any] struct {
+	Item T
+	// Started - false when the pool stopped before the item was picked up
+	Started bool
+	Err     error
+}
+
+// RunPool - runs fn for every item on a pool of workers pulling from a shared queue
+//
+// At most workers calls run at once, a slow item only holds up its own worker.
+// Once ctx is done no further items are started, the running ones are left to finish.
+// Results are returned in the order of items, i is the index of the item.
+func RunPool[T any](ctx context.Context, items []T, workers int, fn func(i int, item T) error) []Result[T] {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(items) {
+		workers = len(items)
+	}
+
+	results := make([]Result[T], len(items))
+	for i := range items {
+		results[i].Item = items[i]
+	}
+	queue := make(chan int)
+
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+
+	for range workers {
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				if ctx.Err() != nil {
+					continue
+				}
+				results[i] = Result[T]{Item: items[i], Started: true, Err: fn(i, items[i])}
+			}
+		}()
+	}
+
+dispatch:
+	for i := range items {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case queue <- i:
+		}
+	}
+	close(queue)
+
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mah35h95/break-time/dice"
+	"github.com/mah35h95/break-time/transport"
+)
+
+// errWaitTimeout - returned (wrapped) for jobs that did not reach the expected state in time
+var errWaitTimeout = errors.New("job did not reach the expected state")
+
+// waitStates - states a job is expected to reach after the command, compared case insensitively
+var waitStates = map[string][]string{
+	dice.Pause:  {"PAUSED"},
+	dice.Stop:   {"STOPPED", "IDLE"},
+	dice.Load:   {"RUNNING", "LOADING"},
+	dice.Reload: {"RUNNING", "LOADING", "RELOADING"},
+}
+
+// waiter - polls the jobs after a command until they reach the expected state
+type waiter struct {
+	enabled     bool
+	states      string
+	timeout     time.Duration
+	interval    time.Duration
+	maxInterval time.Duration
+
+	once  sync.Once
+	mu    sync.Mutex
+	stuck []string
+}
+
+// newWaiter - registers the --wait flags of the command, nil for commands without an expected state
+func newWaiter(fs *flag.FlagSet, cmd string) *waiter {
+	if _, ok := waitStates[cmd]; !ok {
+		return nil
+	}
+
+	w := &waiter{}
+	fs.BoolVar(&w.enabled, "wait", false, "poll every job until it reaches the expected state, jobs that do not are reported as stuck")
+	fs.StringVar(&w.states, "wait-state", strings.Join(waitStates[cmd], ","), "comma separated states that end the wait")
+	fs.DurationVar(&w.timeout, "wait-timeout", 10*time.Minute, "max time to wait for a job")
+	fs.DurationVar(&w.interval, "wait-interval", 5*time.Second, "delay before the first poll, doubled for every following one")
+	fs.DurationVar(&w.maxInterval, "wait-max-interval", time.Minute, "max delay between polls, 0 for no cap")
+	return w
+}
+
+// check - validates the --wait flags, nil for commands without an expected state
+func (w *waiter) check() error {
+	if w == nil || !w.enabled {
+		return nil
+	}
+
+	errs := []error{}
+	if len(w.stateList()) == 0 {
+		errs = append(errs, errors.New("--wait-state is empty"))
+	}
+	if w.timeout <= 0 {
+		errs = append(errs, fmt.Errorf("--wait-timeout must be positive, got %s", w.timeout))
+	}
+	if w.interval <= 0 {
+		errs = append(errs, fmt.Errorf("--wait-interval must be positive, got %s", w.interval))
+	}
+	if w.maxInterval < 0 {
+		errs = append(errs, fmt.Errorf("--wait-max-interval can not be negative, got %s", w.maxInterval))
+	}
+	return errors.Join(errs...)
+}
+
+// stateList - returns the upper cased states of --wait-state
+func (w *waiter) stateList() []string {
+	states := []string{}
+	for _, state := range strings.Split(w.states, ",") {
+		if state = strings.TrimSpace(state); state != "" {
+			states = append(states, strings.ToUpper(state))
+		}
+	}
+	return states
+}
+
+// wait - polls the job status with backoff until the job is in one of the expected states
+func (w *waiter) wait(ctx context.Context, rc *runContext, id dice.DataSourceID) error {
+	if w == nil || !w.enabled {
+		return nil
+	}
+
+	states := w.stateList()
+
+	if rc.client.DryRun() {
+		fmt.Fprintf(rc.progress, "Dry run: would wait for job %s to be %s\n", id, strings.Join(states, " or "))
+		return nil
+	}
+
+	w.once.Do(func() {
+		rc.atFinish(func() error {
+			return w.report(rc.progress)
+		})
+	})
+
+	waitCtx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+
+	policy := transport.RetryPolicy{BaseDelay: w.interval, MaxDelay: w.maxInterval}
+	started := time.Now()
+	last := "unknown"
+
+	for attempt := 1; ; attempt++ {
+		if err := transport.Sleep(waitCtx, policy.Backoff(attempt)); err != nil {
+			break
+		}
+
+		status, err := rc.client.GetJobStatus(waitCtx, id)
+		switch {
+		case err != nil:
+			fmt.Fprintf(rc.progress, "Job %s status: %v\n", id, err)
+		case slices.Contains(states, strings.ToUpper(status.State)):
+			fmt.Fprintf(rc.progress, "Job %s is %s after %s\n", id, status.State, time.Since(started).Round(time.Second))
+			return nil
+		default:
+			last = status.State
+		}
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	w.mu.Lock()
+	w.stuck = append(w.stuck, fmt.Sprintf("%s: %s", id, last))
+	w.mu.Unlock()
+
+	return fmt.Errorf("%w %s within %s, last state %s", errWaitTimeout, strings.Join(states, " or "), w.timeout, last)
+}
+
+// report - lists the jobs that did not reach the expected state to out
+func (w *waiter) report(out io.Writer) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	slices.Sort(w.stuck)
+	printList(out, fmt.Sprintf("Stuck jobs, not %s after %s:", w.states, w.timeout), w.stuck)
+	return nil
+}